@@ -0,0 +1,516 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+// overridableFields are the product columns an admin can curate. Link and
+// store identify a listing during imports and are never overridden.
+var overridableFields = []string{
+	"title", "manufacturer", "price", "code", "warranty", "category", "description", "image",
+	"availability", "delivery_estimate",
+}
+
+// bulkEditableFields are the fields that make sense to set to one value
+// across many listings at once.
+var bulkEditableFields = []string{
+	"manufacturer", "warranty", "category", "description", "image", "availability", "delivery_estimate",
+}
+
+const maxProductTitleLength = 300
+
+// ProductPatch holds the fields to change on one or more products; nil
+// fields are left as they are.
+type ProductPatch struct {
+	Title            *string `json:"title"`
+	Manufacturer     *string `json:"manufacturer"`
+	Price            *int64  `json:"price"`
+	Code             *string `json:"code"`
+	Warranty         *int64  `json:"warranty"`
+	Link             *string `json:"link"`
+	Category         *string `json:"category"`
+	Description      *string `json:"description"`
+	Image            *string `json:"image"`
+	Store            *string `json:"store"`
+	Availability     *string `json:"availability"`
+	DeliveryEstimate *string `json:"deliveryEstimate"`
+	// OverriddenFields, when present, replaces the product's list of
+	// curated fields, e.g. [] hands every field back to the import.
+	OverriddenFields *[]string `json:"overriddenFields"`
+}
+
+// bulkValues returns the bulk-editable fields set in the patch, keyed by
+// column.
+func (patch *ProductPatch) bulkValues() map[string]any {
+	values := map[string]any{}
+	if patch.Manufacturer != nil {
+		values["manufacturer"] = *patch.Manufacturer
+	}
+	if patch.Warranty != nil {
+		values["warranty"] = *patch.Warranty
+	}
+	if patch.Category != nil {
+		values["category"] = *patch.Category
+	}
+	if patch.Description != nil {
+		values["description"] = *patch.Description
+	}
+	if patch.Image != nil {
+		values["image"] = *patch.Image
+	}
+	if patch.Availability != nil {
+		values["availability"] = *patch.Availability
+	}
+	if patch.DeliveryEstimate != nil {
+		values["delivery_estimate"] = *patch.DeliveryEstimate
+	}
+	return values
+}
+
+// apply changes p and returns the overridable fields that were set.
+func (patch *ProductPatch) apply(p *Product) []string {
+	var changed []string
+	setString := func(column string, dst *string, v *string) {
+		if v != nil {
+			*dst = *v
+			changed = append(changed, column)
+		}
+	}
+	setInt := func(column string, dst *int64, v *int64) {
+		if v != nil {
+			*dst = *v
+			changed = append(changed, column)
+		}
+	}
+	setString("title", &p.Title, patch.Title)
+	setString("manufacturer", &p.Manufacturer, patch.Manufacturer)
+	setInt("price", &p.Price, patch.Price)
+	setString("code", &p.Code, patch.Code)
+	setInt("warranty", &p.Warranty, patch.Warranty)
+	setString("category", &p.Category, patch.Category)
+	setString("description", &p.Description, patch.Description)
+	setString("image", &p.Image, patch.Image)
+	setString("availability", &p.Availability, patch.Availability)
+	setString("delivery_estimate", &p.DeliveryEstimate, patch.DeliveryEstimate)
+	if patch.Link != nil {
+		p.Link = *patch.Link
+	}
+	if patch.Store != nil {
+		p.Store = *patch.Store
+	}
+	return changed
+}
+
+func validateProduct(p *Product) error {
+	p.Title = strings.TrimSpace(p.Title)
+	p.Store = strings.TrimSpace(p.Store)
+	p.Link = strings.TrimSpace(p.Link)
+	p.DeliveryEstimate = strings.TrimSpace(p.DeliveryEstimate)
+	if p.Availability == "" {
+		p.Availability = AvailabilityUnknown
+	}
+
+	if p.Title == "" || len([]rune(p.Title)) > maxProductTitleLength {
+		return statusErrorf(http.StatusUnprocessableEntity, "title must be between 1 and %d characters", maxProductTitleLength)
+	}
+	if p.Store == "" {
+		return statusErrorf(http.StatusUnprocessableEntity, "store is required")
+	}
+	if !isHTTPURL(p.Link) {
+		return statusErrorf(http.StatusUnprocessableEntity, "link must be an http(s) URL")
+	}
+	if p.Image != "" && !isHTTPURL(p.Image) {
+		return statusErrorf(http.StatusUnprocessableEntity, "image must be an http(s) URL")
+	}
+	if p.Price < 0 {
+		return statusErrorf(http.StatusUnprocessableEntity, "price must not be negative")
+	}
+	if p.Warranty < 0 {
+		return statusErrorf(http.StatusUnprocessableEntity, "warranty must not be negative")
+	}
+	if err := validateAvailability(p.Availability); err != nil {
+		return err
+	}
+	return nil
+}
+
+func isHTTPURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+func validateOverriddenFields(fields []string) error {
+	for _, f := range fields {
+		if !slices.Contains(overridableFields, f) {
+			return statusErrorf(http.StatusUnprocessableEntity, "%q cannot be overridden, must be one of %s", f, strings.Join(overridableFields, ", "))
+		}
+	}
+	return nil
+}
+
+func (s *PostgressStore) GetAdminProduct(id int) (*AdminProduct, error) {
+	product, err := s.GetProductByID(id)
+	if err != nil || product == nil {
+		return nil, err
+	}
+
+	var overridden pq.StringArray
+	if err := s.db.QueryRow(`SELECT overridden_fields FROM products WHERE id = $1`, id).Scan(&overridden); err != nil {
+		return nil, err
+	}
+	return &AdminProduct{Product: product, OverriddenFields: overridden}, nil
+}
+
+// CreateCuratedProduct inserts a product entered by an admin. It returns
+// sql.ErrNoRows when the store already lists a product under the same link.
+func (s *PostgressStore) CreateCuratedProduct(p *AdminProduct) error {
+	return s.db.QueryRow(`
+        INSERT INTO products (title, manufacturer, price, code, warranty, link, category, description, image, store,
+                              overridden_fields, availability, delivery_estimate)
+        SELECT $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13
+        WHERE NOT EXISTS (SELECT 1 FROM products WHERE link = $6 AND store = $10)
+        RETURNING id
+    `, p.Title, p.Manufacturer, p.Price, p.Code, p.Warranty, p.Link, p.Category, p.Description, p.Image, p.Store,
+		pq.Array(p.OverriddenFields), p.Availability, p.DeliveryEstimate).Scan(&p.ID)
+}
+
+// UpdateProduct writes every field of p and reports the price change, if
+// any. It returns sql.ErrNoRows when the product does not exist or another
+// product of the store is already listed under the same link.
+func (s *PostgressStore) UpdateProduct(p *AdminProduct) (*PriceChange, error) {
+	var oldPrice int64
+	err := s.db.QueryRow(`
+        UPDATE products u
+        SET title = $2, manufacturer = $3, price = $4, code = $5, warranty = $6, link = $7,
+            category = $8, description = $9, image = $10, store = $11, overridden_fields = $12,
+            availability = $13, delivery_estimate = $14
+        FROM (SELECT id, price FROM products WHERE id = $1 FOR UPDATE) old
+        WHERE u.id = old.id
+          AND NOT EXISTS (SELECT 1 FROM products WHERE link = $7 AND store = $11 AND id <> $1)
+        RETURNING old.price
+    `, p.ID, p.Title, p.Manufacturer, p.Price, p.Code, p.Warranty, p.Link, p.Category, p.Description, p.Image, p.Store,
+		pq.Array(p.OverriddenFields), p.Availability, p.DeliveryEstimate).Scan(&oldPrice)
+	if err != nil {
+		return nil, err
+	}
+	if oldPrice == p.Price {
+		return nil, nil
+	}
+	return &PriceChange{ProductID: p.ID, OldPrice: oldPrice, NewPrice: p.Price}, nil
+}
+
+// BulkUpdateProducts sets the patched fields on every product matching q and
+// marks them as overridden. It returns the IDs of the products changed.
+func (s *PostgressStore) BulkUpdateProducts(q *ProductQuery, patch *ProductPatch) ([]int, error) {
+	filter := q.where()
+
+	values := patch.bulkValues()
+	var assignments, columns []string
+	for _, column := range bulkEditableFields {
+		if v, ok := values[column]; ok {
+			assignments = append(assignments, column+" = "+filter.nextArg(v))
+			columns = append(columns, column)
+		}
+	}
+	if len(assignments) == 0 {
+		return nil, nil
+	}
+	assignments = append(assignments, fmt.Sprintf(
+		"overridden_fields = ARRAY(SELECT DISTINCT unnest(overridden_fields || %s::text[]))",
+		filter.nextArg(pq.Array(columns))))
+
+	rows, err := s.db.Query("UPDATE products SET "+strings.Join(assignments, ", ")+filter.query+" RETURNING id", filter.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func (s *PostgressStore) CountProducts(q *ProductQuery) (int, error) {
+	filter := q.where()
+	var n int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM products"+filter.query, filter.args...).Scan(&n)
+	return n, err
+}
+
+// productIDArray passes product IDs to a "$n::int[] IS NULL OR id = ANY($n)"
+// condition; nil means every product.
+func productIDArray(ids []int) any {
+	if ids == nil {
+		return nil
+	}
+	array := make(pq.Int64Array, len(ids))
+	for i, id := range ids {
+		array[i] = int64(id)
+	}
+	return array
+}
+
+func (s *PostgressStore) DeleteProduct(id int) error {
+	res, err := s.db.Exec(`DELETE FROM products WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func productIDFromRequest(r *http.Request) (int, error) {
+	var id int
+	if _, err := fmt.Sscanf(mux.Vars(r)["id"], "%d", &id); err != nil {
+		return 0, fmt.Errorf("invalid product ID")
+	}
+	return id, nil
+}
+
+func (s *APIServer) getAdminProduct(r *http.Request) (*AdminProduct, error) {
+	id, err := productIDFromRequest(r)
+	if err != nil {
+		return nil, err
+	}
+	product, err := s.store.GetAdminProduct(id)
+	if err != nil {
+		return nil, fmt.Errorf("could not get product: %w", err)
+	}
+	if product == nil {
+		return nil, statusErrorf(http.StatusNotFound, "product not found")
+	}
+	return product, nil
+}
+
+// productPriceChanged brings configuration totals and price alerts up to
+// date after an admin edit. The edit has already been saved, so failures
+// are only logged.
+func (s *APIServer) productPriceChanged(change *PriceChange) {
+	if change == nil {
+		return
+	}
+	if err := s.store.RefreshConfigurationTotals(); err != nil {
+		log.Printf("could not refresh configuration totals: %v", err)
+	}
+	if err := EvaluatePriceAlerts(s.store, s.notifier, []*PriceChange{change}); err != nil {
+		log.Printf("price alert evaluation failed: %v", err)
+	}
+}
+
+// productsClassified links changed products to their canonical category and
+// brand. Like productPriceChanged it runs after the change is saved.
+func (s *APIServer) productsClassified(productIDs []int) {
+	if err := ApplyCategoryTaxonomy(s.store, productIDs); err != nil {
+		log.Printf("could not categorize products: %v", err)
+	}
+	if err := ApplyBrandDirectory(s.store, productIDs); err != nil {
+		log.Printf("could not link products to brands: %v", err)
+	}
+}
+
+// recordProductChange audits an admin edit. The routes run behind
+// requireRole, which puts the admin on the request context.
+func (s *APIServer) recordProductChange(r *http.Request, action string, details map[string]any) {
+	admin, ok := r.Context().Value(authenticatedUserKey{}).(*User)
+	if !ok {
+		return
+	}
+	details["action"] = action
+	details["changedBy"] = admin.ID
+	s.recordAuditEvent(&AuditEvent{Event: AuditProductChanged, Details: details})
+}
+
+func (s *APIServer) handleAdminGetProduct(w http.ResponseWriter, r *http.Request) error {
+	product, err := s.getAdminProduct(r)
+	if err != nil {
+		return err
+	}
+	return WriteJSON(w, http.StatusOK, product)
+}
+
+func (s *APIServer) handleAdminCreateProduct(w http.ResponseWriter, r *http.Request) error {
+	product := &AdminProduct{Product: &Product{}}
+	if err := json.NewDecoder(r.Body).Decode(product.Product); err != nil {
+		return err
+	}
+	if err := validateProduct(product.Product); err != nil {
+		return err
+	}
+	// Everything about a hand-entered product is curated.
+	product.OverriddenFields = slices.Clone(overridableFields)
+
+	if err := s.store.CreateCuratedProduct(product); err != nil {
+		if err == sql.ErrNoRows || isUniqueViolation(err) {
+			return statusErrorf(http.StatusConflict, "%s already lists a product at this link", product.Store)
+		}
+		return fmt.Errorf("failed to create product: %w", err)
+	}
+	s.productsClassified([]int{product.ID})
+	s.recordProductChange(r, "create", map[string]any{"productID": product.ID})
+
+	return WriteJSON(w, http.StatusCreated, product)
+}
+
+func (s *APIServer) handleAdminReplaceProduct(w http.ResponseWriter, r *http.Request) error {
+	id, err := productIDFromRequest(r)
+	if err != nil {
+		return err
+	}
+	product := &AdminProduct{Product: &Product{}}
+	if err := json.NewDecoder(r.Body).Decode(product.Product); err != nil {
+		return err
+	}
+	product.ID = id
+	if err := validateProduct(product.Product); err != nil {
+		return err
+	}
+	product.OverriddenFields = slices.Clone(overridableFields)
+
+	return s.saveAdminProduct(w, r, product, overridableFields)
+}
+
+func (s *APIServer) handleAdminPatchProduct(w http.ResponseWriter, r *http.Request) error {
+	product, err := s.getAdminProduct(r)
+	if err != nil {
+		return err
+	}
+
+	var patch ProductPatch
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		return err
+	}
+	changed := patch.apply(product.Product)
+	if err := validateProduct(product.Product); err != nil {
+		return err
+	}
+
+	if patch.OverriddenFields != nil {
+		if err := validateOverriddenFields(*patch.OverriddenFields); err != nil {
+			return err
+		}
+		product.OverriddenFields = *patch.OverriddenFields
+	} else {
+		for _, f := range changed {
+			if !slices.Contains(product.OverriddenFields, f) {
+				product.OverriddenFields = append(product.OverriddenFields, f)
+			}
+		}
+	}
+
+	return s.saveAdminProduct(w, r, product, changed)
+}
+
+func (s *APIServer) saveAdminProduct(w http.ResponseWriter, r *http.Request, product *AdminProduct, changed []string) error {
+	change, err := s.store.UpdateProduct(product)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			if existing, err := s.store.GetAdminProduct(product.ID); err == nil && existing != nil {
+				return statusErrorf(http.StatusConflict, "%s already lists another product at this link", product.Store)
+			}
+			return statusErrorf(http.StatusNotFound, "product not found")
+		}
+		if isUniqueViolation(err) {
+			return statusErrorf(http.StatusConflict, "%s already lists another product at this link", product.Store)
+		}
+		return fmt.Errorf("failed to update product: %w", err)
+	}
+	s.productPriceChanged(change)
+	s.productsClassified([]int{product.ID})
+	s.recordProductChange(r, "update", map[string]any{"productID": product.ID, "fields": changed})
+
+	return WriteJSON(w, http.StatusOK, product)
+}
+
+func (s *APIServer) handleAdminBulkUpdateProducts(w http.ResponseWriter, r *http.Request) error {
+	q, err := ParseProductQuery(r.URL.Query())
+	if err != nil {
+		return err
+	}
+
+	var patch ProductPatch
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		return err
+	}
+	if patch.Title != nil || patch.Price != nil || patch.Code != nil || patch.Link != nil || patch.Store != nil || patch.OverriddenFields != nil {
+		return statusErrorf(http.StatusUnprocessableEntity, "bulk edits can only set %s", strings.Join(bulkEditableFields, ", "))
+	}
+	if len(patch.bulkValues()) == 0 {
+		return statusErrorf(http.StatusUnprocessableEntity, "nothing to update")
+	}
+	if patch.Warranty != nil && *patch.Warranty < 0 {
+		return statusErrorf(http.StatusUnprocessableEntity, "warranty must not be negative")
+	}
+	if patch.Image != nil && *patch.Image != "" && !isHTTPURL(*patch.Image) {
+		return statusErrorf(http.StatusUnprocessableEntity, "image must be an http(s) URL")
+	}
+	if patch.Availability != nil {
+		if err := validateAvailability(*patch.Availability); err != nil {
+			return err
+		}
+	}
+
+	// A filter can match everything without looking like it, e.g.
+	// minPrice=0, so the effect is checked rather than the query string.
+	if r.URL.Query().Get("all") != "true" {
+		matched, err := s.store.CountProducts(q)
+		if err != nil {
+			return fmt.Errorf("could not count products: %w", err)
+		}
+		total, err := s.store.CountProducts(&ProductQuery{})
+		if err != nil {
+			return fmt.Errorf("could not count products: %w", err)
+		}
+		if matched == total {
+			return statusErrorf(http.StatusUnprocessableEntity, "this edit would change every product, pass all=true to confirm")
+		}
+	}
+
+	updated, err := s.store.BulkUpdateProducts(q, &patch)
+	if err != nil {
+		return fmt.Errorf("bulk update failed: %w", err)
+	}
+	s.productsClassified(updated)
+	s.recordProductChange(r, "bulk_update", map[string]any{"filter": r.URL.RawQuery, "updated": len(updated)})
+
+	return WriteJSON(w, http.StatusOK, map[string]int{"updated": len(updated)})
+}
+
+func (s *APIServer) handleAdminDeleteProduct(w http.ResponseWriter, r *http.Request) error {
+	id, err := productIDFromRequest(r)
+	if err != nil {
+		return err
+	}
+
+	if err := s.store.DeleteProduct(id); err != nil {
+		if err == sql.ErrNoRows {
+			return statusErrorf(http.StatusNotFound, "product not found")
+		}
+		if isForeignKeyViolation(err) {
+			return statusErrorf(http.StatusConflict, "product is part of saved configurations and cannot be deleted")
+		}
+		return fmt.Errorf("failed to delete product: %w", err)
+	}
+	s.recordProductChange(r, "delete", map[string]any{"productID": id})
+
+	return WriteJSON(w, http.StatusOK, map[string]string{"message": "product deleted"})
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+func TestValidateProduct(t *testing.T) {
+	valid := func() *Product {
+		return &Product{Title: " RTX 4070 ", Store: "Anhoch", Link: "https://anhoch.com/p/1"}
+	}
+
+	p := valid()
+	if err := validateProduct(p); err != nil {
+		t.Fatalf("valid product: %v", err)
+	}
+	if p.Title != "RTX 4070" || p.Availability != AvailabilityUnknown {
+		t.Errorf("normalized = %q, %q", p.Title, p.Availability)
+	}
+
+	tests := map[string]func(p *Product){
+		"no title":       func(p *Product) { p.Title = " " },
+		"no store":       func(p *Product) { p.Store = "" },
+		"bad link":       func(p *Product) { p.Link = "ftp://example.com" },
+		"bad image":      func(p *Product) { p.Image = "not a url" },
+		"negative price": func(p *Product) { p.Price = -1 },
+		"bad warranty":   func(p *Product) { p.Warranty = -12 },
+		"availability":   func(p *Product) { p.Availability = "maybe" },
+	}
+	for name, mutate := range tests {
+		p := valid()
+		mutate(p)
+		var statusErr *StatusError
+		if err := validateProduct(p); !errors.As(err, &statusErr) || statusErr.Status != http.StatusUnprocessableEntity {
+			t.Errorf("%s: err = %v, want 422", name, err)
+		}
+	}
+}
+
+func TestProductPatchApply(t *testing.T) {
+	title, store := "New title", "Setec"
+	price := int64(1999)
+	p := &Product{Title: "Old", Price: 2500, Store: "Anhoch"}
+
+	changed := (&ProductPatch{Title: &title, Price: &price, Store: &store}).apply(p)
+	if p.Title != title || p.Price != price || p.Store != store {
+		t.Errorf("product = %+v", p)
+	}
+	// Store identifies the listing and is never reported as overridden.
+	if !slices.Equal(changed, []string{"title", "price"}) {
+		t.Errorf("changed = %v", changed)
+	}
+}
+
+func TestProductPatchBulkValues(t *testing.T) {
+	manufacturer, title := "ASUS", "ignored"
+	values := (&ProductPatch{Manufacturer: &manufacturer, Title: &title}).bulkValues()
+	if len(values) != 1 || values["manufacturer"] != manufacturer {
+		t.Errorf("bulkValues = %v", values)
+	}
+}
+
+func TestValidateOverriddenFields(t *testing.T) {
+	if err := validateOverriddenFields([]string{"title", "price"}); err != nil {
+		t.Errorf("valid fields: %v", err)
+	}
+	if err := validateOverriddenFields([]string{"link"}); err == nil {
+		t.Error("link accepted as overridable")
+	}
+}
+
+func TestProductIDArray(t *testing.T) {
+	if got := productIDArray(nil); got != nil {
+		t.Errorf("productIDArray(nil) = %v, want nil", got)
+	}
+	if got, ok := productIDArray([]int{}).(pq.Int64Array); !ok || got == nil || len(got) != 0 {
+		t.Errorf("productIDArray([]) = %#v, want an empty array", got)
+	}
+	if got := productIDArray([]int{3, 7}); !slices.Equal(got.(pq.Int64Array), pq.Int64Array{3, 7}) {
+		t.Errorf("productIDArray = %v", got)
+	}
+}
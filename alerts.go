@@ -0,0 +1,292 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+const maxAlertDropPercent = 90
+
+func (s *PostgressStore) CreatePriceAlertsTable() error {
+	_, err := s.db.Exec(`
+    CREATE TABLE IF NOT EXISTS price_alerts (
+        id SERIAL PRIMARY KEY,
+        user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+        product_id INTEGER NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+        target_price BIGINT,
+        drop_percent INTEGER,
+        baseline_price BIGINT NOT NULL,
+        created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+        triggered_at TIMESTAMPTZ,
+        triggered_price BIGINT,
+        CHECK (target_price IS NOT NULL OR drop_percent IS NOT NULL)
+    );
+    CREATE INDEX IF NOT EXISTS price_alerts_active_idx
+        ON price_alerts (product_id) WHERE triggered_at IS NULL;
+    ALTER TABLE price_alerts ADD COLUMN IF NOT EXISTS notify_failed_at TIMESTAMPTZ;
+    `)
+	return err
+}
+
+const priceAlertColumns = "a.id, a.user_id, a.product_id, a.target_price, a.drop_percent, a.baseline_price, a.created_at, a.triggered_at, a.triggered_price, u.email"
+
+func scanIntoPriceAlert(row rowScanner) (*PriceAlert, error) {
+	a := new(PriceAlert)
+	var target, triggeredPrice sql.NullInt64
+	var percent sql.NullInt32
+	var triggeredAt sql.NullTime
+	err := row.Scan(&a.ID, &a.UserID, &a.ProductID, &target, &percent, &a.BaselinePrice,
+		&a.CreatedAt, &triggeredAt, &triggeredPrice, &a.UserEmail)
+	if err != nil {
+		return nil, err
+	}
+	if target.Valid {
+		a.TargetPrice = &target.Int64
+	}
+	if percent.Valid {
+		p := int(percent.Int32)
+		a.DropPercent = &p
+	}
+	if triggeredAt.Valid {
+		a.TriggeredAt = &triggeredAt.Time
+	}
+	if triggeredPrice.Valid {
+		a.TriggeredPrice = &triggeredPrice.Int64
+	}
+	return a, nil
+}
+
+func (s *PostgressStore) CreatePriceAlert(a *PriceAlert) error {
+	return s.db.QueryRow(`
+        INSERT INTO price_alerts (user_id, product_id, target_price, drop_percent, baseline_price)
+        VALUES ($1, $2, $3, $4, $5)
+        RETURNING id, created_at
+    `, a.UserID, a.ProductID, a.TargetPrice, a.DropPercent, a.BaselinePrice).Scan(&a.ID, &a.CreatedAt)
+}
+
+func (s *PostgressStore) GetPriceAlertsByUserID(userID int) ([]*PriceAlert, error) {
+	return s.queryPriceAlerts(`
+        SELECT `+priceAlertColumns+`
+        FROM price_alerts a
+        JOIN users u ON u.id = a.user_id
+        WHERE a.user_id = $1
+        ORDER BY a.created_at DESC
+    `, userID)
+}
+
+// GetActivePriceAlerts returns the alerts that have not fired yet on the
+// given products, and those whose notification failed before, for users with
+// a verified email address.
+func (s *PostgressStore) GetActivePriceAlerts(productIDs []int) ([]*PriceAlert, error) {
+	return s.queryPriceAlerts(`
+        SELECT `+priceAlertColumns+`
+        FROM price_alerts a
+        JOIN users u ON u.id = a.user_id
+        WHERE (a.product_id = ANY($1) OR a.notify_failed_at IS NOT NULL)
+          AND a.triggered_at IS NULL
+          AND u.verified_at IS NOT NULL
+    `, pq.Array(productIDs))
+}
+
+func (s *PostgressStore) queryPriceAlerts(query string, args ...any) ([]*PriceAlert, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	alerts := []*PriceAlert{}
+	for rows.Next() {
+		a, err := scanIntoPriceAlert(rows)
+		if err != nil {
+			return nil, err
+		}
+		alerts = append(alerts, a)
+	}
+	return alerts, rows.Err()
+}
+
+func (s *PostgressStore) DeletePriceAlert(id, userID int) error {
+	res, err := s.db.Exec(`DELETE FROM price_alerts WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (s *PostgressStore) MarkPriceAlertTriggered(id int, price int64) error {
+	_, err := s.db.Exec(`
+        UPDATE price_alerts SET triggered_at = NOW(), triggered_price = $2, notify_failed_at = NULL WHERE id = $1
+    `, id, price)
+	return err
+}
+
+// MarkPriceAlertFailed keeps an alert whose notification could not be sent
+// due for the next evaluation.
+func (s *PostgressStore) MarkPriceAlertFailed(id int) error {
+	_, err := s.db.Exec(`UPDATE price_alerts SET notify_failed_at = NOW() WHERE id = $1`, id)
+	return err
+}
+
+// thresholdPrice is the price at or below which the alert fires.
+func (a *PriceAlert) thresholdPrice() int64 {
+	threshold := int64(-1)
+	if a.TargetPrice != nil {
+		threshold = *a.TargetPrice
+	}
+	if a.DropPercent != nil {
+		if t := a.BaselinePrice * int64(100-*a.DropPercent) / 100; t > threshold {
+			threshold = t
+		}
+	}
+	return threshold
+}
+
+// EvaluatePriceAlerts notifies the owners of alerts on products that got
+// cheaper in an import. Each alert fires once; alerts whose notification
+// failed are retried on the next evaluation while the price still qualifies.
+func EvaluatePriceAlerts(store Storage, notifier Notifier, changes []*PriceChange) error {
+	drops := map[int]*PriceChange{}
+	var ids []int
+	for _, c := range changes {
+		if c.NewPrice < c.OldPrice && c.NewPrice > 0 {
+			drops[c.ProductID] = c
+			ids = append(ids, c.ProductID)
+		}
+	}
+
+	alerts, err := store.GetActivePriceAlerts(ids)
+	if err != nil {
+		return err
+	}
+
+	for _, a := range alerts {
+		if drop := drops[a.ProductID]; drop != nil && drop.NewPrice > a.thresholdPrice() {
+			continue
+		}
+
+		product, err := store.GetProductByID(a.ProductID)
+		if err != nil || product == nil {
+			log.Printf("price alert %d: could not load product %d: %v", a.ID, a.ProductID, err)
+			continue
+		}
+		if product.Price <= 0 || product.Price > a.thresholdPrice() {
+			continue
+		}
+		oldPrice := a.BaselinePrice
+		if drop := drops[a.ProductID]; drop != nil {
+			oldPrice = drop.OldPrice
+		}
+
+		msg := &Message{
+			To:      a.UserEmail,
+			Subject: fmt.Sprintf("Price drop: %s", product.Title),
+			Body: fmt.Sprintf("%s at %s is now %s (was %s).\n\n%s\n",
+				product.Title, product.Store, formatPrice(product.Price), formatPrice(oldPrice), product.Link),
+		}
+		if err := notifier.Notify(msg); err != nil {
+			log.Printf("price alert %d: could not notify user %d: %v", a.ID, a.UserID, err)
+			if err := store.MarkPriceAlertFailed(a.ID); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := store.MarkPriceAlertTriggered(a.ID, product.Price); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *APIServer) handleCreatePriceAlert(w http.ResponseWriter, r *http.Request) error {
+	userID, err := s.requireVerifiedUser(r)
+	if err != nil {
+		return err
+	}
+
+	var req struct {
+		ProductID   int    `json:"productID"`
+		TargetPrice *int64 `json:"targetPrice"`
+		DropPercent *int   `json:"dropPercent"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return err
+	}
+	if (req.TargetPrice == nil) == (req.DropPercent == nil) {
+		return statusErrorf(http.StatusUnprocessableEntity, "set exactly one of targetPrice or dropPercent")
+	}
+
+	product, err := s.store.GetProductByID(req.ProductID)
+	if err != nil {
+		return fmt.Errorf("could not fetch product: %w", err)
+	}
+	if product == nil {
+		return statusErrorf(http.StatusNotFound, "product not found")
+	}
+
+	if req.TargetPrice != nil && (*req.TargetPrice <= 0 || *req.TargetPrice >= product.Price) {
+		return statusErrorf(http.StatusUnprocessableEntity, "targetPrice must be below the current price of %s", formatPrice(product.Price))
+	}
+	if req.DropPercent != nil && (*req.DropPercent < 1 || *req.DropPercent > maxAlertDropPercent) {
+		return statusErrorf(http.StatusUnprocessableEntity, "dropPercent must be between 1 and %d", maxAlertDropPercent)
+	}
+
+	alert := &PriceAlert{
+		UserID:        userID,
+		ProductID:     product.ID,
+		TargetPrice:   req.TargetPrice,
+		DropPercent:   req.DropPercent,
+		BaselinePrice: product.Price,
+		Product:       product,
+	}
+	if err := s.store.CreatePriceAlert(alert); err != nil {
+		return fmt.Errorf("failed to create alert: %w", err)
+	}
+
+	return WriteJSON(w, http.StatusCreated, alert)
+}
+
+func (s *APIServer) handleGetPriceAlerts(w http.ResponseWriter, r *http.Request) error {
+	userID, err := s.userIDFromRequest(r)
+	if err != nil {
+		return err
+	}
+
+	alerts, err := s.store.GetPriceAlertsByUserID(userID)
+	if err != nil {
+		return fmt.Errorf("could not get alerts: %w", err)
+	}
+	return WriteJSON(w, http.StatusOK, alerts)
+}
+
+func (s *APIServer) handleDeletePriceAlert(w http.ResponseWriter, r *http.Request) error {
+	userID, err := s.userIDFromRequest(r)
+	if err != nil {
+		return err
+	}
+
+	var id int
+	if _, err := fmt.Sscanf(mux.Vars(r)["id"], "%d", &id); err != nil {
+		return fmt.Errorf("invalid alert ID")
+	}
+
+	if err := s.store.DeletePriceAlert(id, userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return statusErrorf(http.StatusNotFound, "alert not found")
+		}
+		return fmt.Errorf("failed to delete alert: %w", err)
+	}
+	return WriteJSON(w, http.StatusOK, map[string]string{"message": "alert deleted"})
+}
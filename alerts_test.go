@@ -0,0 +1,181 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeAlertStore implements the part of Storage that alert evaluation uses.
+type fakeAlertStore struct {
+	Storage
+	alerts    []*PriceAlert
+	products  map[int]*Product
+	failed    map[int]bool
+	triggered map[int]int64
+}
+
+func newFakeAlertStore(product *Product, alerts ...*PriceAlert) *fakeAlertStore {
+	return &fakeAlertStore{
+		alerts:    alerts,
+		products:  map[int]*Product{product.ID: product},
+		failed:    map[int]bool{},
+		triggered: map[int]int64{},
+	}
+}
+
+func (s *fakeAlertStore) GetActivePriceAlerts(productIDs []int) ([]*PriceAlert, error) {
+	var active []*PriceAlert
+	for _, a := range s.alerts {
+		if a.TriggeredAt == nil && (slices.Contains(productIDs, a.ProductID) || s.failed[a.ID]) {
+			active = append(active, a)
+		}
+	}
+	return active, nil
+}
+
+func (s *fakeAlertStore) GetProductByID(id int) (*Product, error) {
+	return s.products[id], nil
+}
+
+func (s *fakeAlertStore) MarkPriceAlertTriggered(id int, price int64) error {
+	for _, a := range s.alerts {
+		if a.ID == id {
+			now := time.Now()
+			a.TriggeredAt = &now
+		}
+	}
+	s.triggered[id] = price
+	delete(s.failed, id)
+	return nil
+}
+
+func (s *fakeAlertStore) MarkPriceAlertFailed(id int) error {
+	s.failed[id] = true
+	return nil
+}
+
+type fakeNotifier struct {
+	sent []*Message
+	err  error
+}
+
+func (n *fakeNotifier) Notify(msg *Message) error {
+	if n.err != nil {
+		return n.err
+	}
+	n.sent = append(n.sent, msg)
+	return nil
+}
+
+func int64Ptr(v int64) *int64 { return &v }
+func intPtr(v int) *int       { return &v }
+
+func TestThresholdPrice(t *testing.T) {
+	tests := []struct {
+		name  string
+		alert PriceAlert
+		want  int64
+	}{
+		{"target", PriceAlert{TargetPrice: int64Ptr(800), BaselinePrice: 1000}, 800},
+		{"drop percent", PriceAlert{DropPercent: intPtr(10), BaselinePrice: 1000}, 900},
+		{"higher of both", PriceAlert{TargetPrice: int64Ptr(800), DropPercent: intPtr(10), BaselinePrice: 1000}, 900},
+		{"neither", PriceAlert{BaselinePrice: 1000}, -1},
+	}
+	for _, tt := range tests {
+		if got := tt.alert.thresholdPrice(); got != tt.want {
+			t.Errorf("%s: thresholdPrice = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestEvaluatePriceAlerts(t *testing.T) {
+	tests := []struct {
+		name         string
+		alert        *PriceAlert
+		currentPrice int64
+		change       *PriceChange
+		fires        bool
+	}{
+		{"drop below target", &PriceAlert{TargetPrice: int64Ptr(800)}, 750, &PriceChange{OldPrice: 1000, NewPrice: 750}, true},
+		{"drop to target", &PriceAlert{TargetPrice: int64Ptr(800)}, 800, &PriceChange{OldPrice: 1000, NewPrice: 800}, true},
+		{"drop above target", &PriceAlert{TargetPrice: int64Ptr(800)}, 850, &PriceChange{OldPrice: 1000, NewPrice: 850}, false},
+		{"rise", &PriceAlert{TargetPrice: int64Ptr(800)}, 700, &PriceChange{OldPrice: 600, NewPrice: 700}, false},
+		{"drop percent reached", &PriceAlert{DropPercent: intPtr(10)}, 900, &PriceChange{OldPrice: 1000, NewPrice: 900}, true},
+		{"drop percent not reached", &PriceAlert{DropPercent: intPtr(10)}, 950, &PriceChange{OldPrice: 1000, NewPrice: 950}, false},
+		{"unpriced", &PriceAlert{TargetPrice: int64Ptr(800)}, 0, &PriceChange{OldPrice: 1000, NewPrice: 0}, false},
+		{"price changed again since", &PriceAlert{TargetPrice: int64Ptr(800)}, 990, &PriceChange{OldPrice: 1000, NewPrice: 750}, false},
+	}
+	for _, tt := range tests {
+		tt.alert.ID, tt.alert.ProductID, tt.alert.BaselinePrice, tt.alert.UserEmail = 1, 1, 1000, "user@example.com"
+		tt.change.ProductID = 1
+		store := newFakeAlertStore(&Product{ID: 1, Title: "RTX 4070", Store: "Anhoch", Price: tt.currentPrice}, tt.alert)
+		notifier := &fakeNotifier{}
+
+		if err := EvaluatePriceAlerts(store, notifier, []*PriceChange{tt.change}); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if fired := len(notifier.sent) == 1; fired != tt.fires {
+			t.Errorf("%s: fired = %v, want %v", tt.name, fired, tt.fires)
+			continue
+		}
+		if !tt.fires {
+			continue
+		}
+		if price, ok := store.triggered[1]; !ok || price != tt.currentPrice {
+			t.Errorf("%s: triggered at %d (%v), want %d", tt.name, price, ok, tt.currentPrice)
+		}
+		msg := notifier.sent[0]
+		if msg.To != "user@example.com" || !strings.Contains(msg.Body, "(was "+formatPrice(tt.change.OldPrice)+")") {
+			t.Errorf("%s: message = %+v", tt.name, msg)
+		}
+	}
+}
+
+func TestEvaluatePriceAlertsRetriesFailedNotifications(t *testing.T) {
+	product := &Product{ID: 1, Title: "RTX 4070", Store: "Anhoch", Price: 750}
+	alert := &PriceAlert{ID: 1, ProductID: 1, TargetPrice: int64Ptr(800), BaselinePrice: 1000, UserEmail: "user@example.com"}
+	store := newFakeAlertStore(product, alert)
+	notifier := &fakeNotifier{err: errors.New("smtp down")}
+
+	drop := []*PriceChange{{ProductID: 1, OldPrice: 1000, NewPrice: 750}}
+	if err := EvaluatePriceAlerts(store, notifier, drop); err != nil {
+		t.Fatal(err)
+	}
+	if !store.failed[1] || alert.TriggeredAt != nil {
+		t.Fatalf("after failed notification: failed = %v, triggered = %v", store.failed[1], alert.TriggeredAt)
+	}
+
+	// The next import has no change for the product; the alert is retried
+	// and reports the price it was created at.
+	notifier.err = nil
+	if err := EvaluatePriceAlerts(store, notifier, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(notifier.sent) != 1 || alert.TriggeredAt == nil || store.failed[1] {
+		t.Fatalf("retry: sent = %d, triggered = %v, failed = %v", len(notifier.sent), alert.TriggeredAt, store.failed[1])
+	}
+	if !strings.Contains(notifier.sent[0].Body, "(was "+formatPrice(1000)+")") {
+		t.Errorf("retry message = %q", notifier.sent[0].Body)
+	}
+	if err := EvaluatePriceAlerts(store, notifier, nil); err != nil || len(notifier.sent) != 1 {
+		t.Errorf("alert fired again: sent = %d, err = %v", len(notifier.sent), err)
+	}
+}
+
+func TestEvaluatePriceAlertsSkipsRetryWhenPriceRose(t *testing.T) {
+	product := &Product{ID: 1, Price: 950}
+	alert := &PriceAlert{ID: 1, ProductID: 1, TargetPrice: int64Ptr(800), BaselinePrice: 1000}
+	store := newFakeAlertStore(product, alert)
+	store.failed[1] = true
+	notifier := &fakeNotifier{}
+
+	if err := EvaluatePriceAlerts(store, notifier, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(notifier.sent) != 0 || alert.TriggeredAt != nil {
+		t.Errorf("sent = %d, triggered = %v", len(notifier.sent), alert.TriggeredAt)
+	}
+}
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -14,8 +16,11 @@ import (
 )
 
 type APIServer struct {
-	listenAddr string
-	store      Storage
+	listenAddr     string
+	store          Storage
+	passwordPolicy *PasswordPolicy
+	notifier       Notifier
+	loginLimiter   *LoginLimiter
 }
 
 func (s *APIServer) Run() {
@@ -26,15 +31,71 @@ func (s *APIServer) Run() {
 	router.HandleFunc("/image-proxy", makeHTTPHandleFunc(s.handleImageProxy))
 	router.HandleFunc("/manufacturers", makeHTTPHandleFunc(s.handleGetManufacturers))
 	router.HandleFunc("/stores", makeHTTPHandleFunc(s.handleGetStores))
+	router.HandleFunc("/stores/{id}", makeHTTPHandleFunc(s.handleGetStore)).Methods("GET")
+	router.HandleFunc("/categories", makeHTTPHandleFunc(s.handleGetCategories)).Methods("GET")
+	router.HandleFunc("/brands/{id}", makeHTTPHandleFunc(s.handleGetBrand)).Methods("GET")
 	router.HandleFunc("/product/{id}", makeHTTPHandleFunc(s.handleGetProductById))
+	router.HandleFunc("/product/{id}/stock-history", makeHTTPHandleFunc(s.handleGetStockHistory)).Methods("GET")
 	router.HandleFunc("/register", makeHTTPHandleFunc(s.handleRegister)).Methods("POST")
 	router.HandleFunc("/login", makeHTTPHandleFunc(s.handleLogin)).Methods("POST")
+	router.HandleFunc("/verify-email", makeHTTPHandleFunc(s.handleVerifyEmail)).Methods("GET")
+	router.HandleFunc("/verify-email/resend", makeHTTPHandleFunc(s.handleResendVerificationEmail)).Methods("POST")
+	router.HandleFunc("/password/forgot", makeHTTPHandleFunc(s.handleForgotPassword)).Methods("POST")
+	router.HandleFunc("/password/reset", makeHTTPHandleFunc(s.handleResetPassword)).Methods("POST")
+	router.HandleFunc("/password/change", makeHTTPHandleFunc(s.handleChangePassword)).Methods("POST")
+	router.HandleFunc("/admin/users/{id}/role", makeHTTPHandleFunc(s.requireRole(RoleAdmin, s.handleSetUserRole))).Methods("PUT")
+	router.HandleFunc("/admin/products", makeHTTPHandleFunc(s.requireRole(RoleAdmin, s.handleAdminCreateProduct))).Methods("POST")
+	router.HandleFunc("/admin/products", makeHTTPHandleFunc(s.requireRole(RoleAdmin, s.handleAdminBulkUpdateProducts))).Methods("PATCH")
+	router.HandleFunc("/admin/products/{id}", makeHTTPHandleFunc(s.requireRole(RoleAdmin, s.handleAdminGetProduct))).Methods("GET")
+	router.HandleFunc("/admin/products/{id}", makeHTTPHandleFunc(s.requireRole(RoleAdmin, s.handleAdminReplaceProduct))).Methods("PUT")
+	router.HandleFunc("/admin/products/{id}", makeHTTPHandleFunc(s.requireRole(RoleAdmin, s.handleAdminPatchProduct))).Methods("PATCH")
+	router.HandleFunc("/admin/products/{id}", makeHTTPHandleFunc(s.requireRole(RoleAdmin, s.handleAdminDeleteProduct))).Methods("DELETE")
+	router.HandleFunc("/admin/categories", makeHTTPHandleFunc(s.requireRole(RoleEditor, s.handleCreateCategory))).Methods("POST")
+	router.HandleFunc("/admin/category-mappings", makeHTTPHandleFunc(s.requireRole(RoleEditor, s.handleGetCategoryMappings))).Methods("GET")
+	router.HandleFunc("/admin/category-mappings", makeHTTPHandleFunc(s.requireRole(RoleEditor, s.handleSetCategoryMapping))).Methods("PUT")
+	router.HandleFunc("/admin/category-mappings/unmapped", makeHTTPHandleFunc(s.requireRole(RoleEditor, s.handleGetUnmappedCategories))).Methods("GET")
+	router.HandleFunc("/admin/category-mappings/{id}", makeHTTPHandleFunc(s.requireRole(RoleEditor, s.handleDeleteCategoryMapping))).Methods("DELETE")
+	router.HandleFunc("/admin/brands/{id}", makeHTTPHandleFunc(s.requireRole(RoleEditor, s.handleUpdateBrand))).Methods("PUT", "PATCH")
+	router.HandleFunc("/admin/brands/{id}/aliases", makeHTTPHandleFunc(s.requireRole(RoleEditor, s.handleAddBrandAlias))).Methods("POST")
+	router.HandleFunc("/admin/brands/{id}/merge", makeHTTPHandleFunc(s.requireRole(RoleEditor, s.handleMergeBrand))).Methods("POST")
+	router.HandleFunc("/admin/stores/{id}", makeHTTPHandleFunc(s.requireRole(RoleAdmin, s.handleAdminGetStore))).Methods("GET")
+	router.HandleFunc("/admin/stores/{id}", makeHTTPHandleFunc(s.requireRole(RoleAdmin, s.handleAdminUpdateStore))).Methods("PUT", "PATCH")
 	router.HandleFunc("/api/youtube", handleYouTubeSearch)
 	router.HandleFunc("/configurations", makeHTTPHandleFunc(s.handleCreateConfiguration)).Methods("POST")
+	router.HandleFunc("/configurations/import", makeHTTPHandleFunc(s.handleImportConfiguration)).Methods("POST")
+	router.HandleFunc("/configurations/{id}", makeHTTPHandleFunc(s.handleGetConfiguration)).Methods("GET")
+	router.HandleFunc("/configurations/{id}", makeHTTPHandleFunc(s.handleRenameConfiguration)).Methods("PUT", "PATCH")
+	router.HandleFunc("/configurations/{id}", makeHTTPHandleFunc(s.handleDeleteConfiguration)).Methods("DELETE")
+	router.HandleFunc("/configurations/{id}/duplicate", makeHTTPHandleFunc(s.handleDuplicateConfiguration)).Methods("POST")
 	router.HandleFunc("/configurations/{id}/products", makeHTTPHandleFunc(s.handleAddProductToConfiguration)).Methods("POST")
 	router.HandleFunc("/configurations/{id}/products/{productID}", makeHTTPHandleFunc(s.handleRemoveProductFromConfiguration)).Methods("DELETE")
+	router.HandleFunc("/configurations/{id}/items/{itemID}", makeHTTPHandleFunc(s.handleSetConfigurationItemQuantity)).Methods("PATCH")
+	router.HandleFunc("/configurations/{id}/items/{itemID}", makeHTTPHandleFunc(s.handleRemoveConfigurationItem)).Methods("DELETE")
+	router.HandleFunc("/configurations/{id}/slots/{slot}", makeHTTPHandleFunc(s.handleSetConfigurationSlot)).Methods("PUT")
+	router.HandleFunc("/configurations/{id}/history", makeHTTPHandleFunc(s.handleGetConfigurationHistory)).Methods("GET")
+	router.HandleFunc("/configurations/{id}/history/diff", makeHTTPHandleFunc(s.handleDiffConfigurationRevisions)).Methods("GET")
+	router.HandleFunc("/configurations/{id}/history/{revisionID}/restore", makeHTTPHandleFunc(s.handleRestoreConfigurationRevision)).Methods("POST")
+	router.HandleFunc("/configurations/{id}/export", makeHTTPHandleFunc(s.handleExportConfiguration)).Methods("GET")
+	router.HandleFunc("/configurations/{id}/optimize", makeHTTPHandleFunc(s.handleOptimizeConfiguration)).Methods("GET")
+	router.HandleFunc("/configurations/{id}/power", makeHTTPHandleFunc(s.handleGetConfigurationPower)).Methods("GET")
+	router.HandleFunc("/configurations/{id}/visibility", makeHTTPHandleFunc(s.handleSetConfigurationVisibility)).Methods("PUT")
+	router.HandleFunc("/builds", makeHTTPHandleFunc(s.handleGetPublicBuilds)).Methods("GET")
+	router.HandleFunc("/builds/generate", makeHTTPHandleFunc(s.handleGenerateBuild)).Methods("POST")
+	router.HandleFunc("/builds/{slug}", makeHTTPHandleFunc(s.handleGetSharedBuild)).Methods("GET")
+	router.HandleFunc("/builds/{slug}/export", makeHTTPHandleFunc(s.handleExportSharedBuild)).Methods("GET")
+	router.HandleFunc("/builds/{slug}/clone", makeHTTPHandleFunc(s.handleCloneSharedBuild)).Methods("POST")
 	router.HandleFunc("/users/{userID}/configurations", makeHTTPHandleFunc(s.handleGetConfigurationsByUser)).Methods("GET")
 	router.HandleFunc("/products/random", makeHTTPHandleFunc(s.handleGetRandomProducts)).Methods("GET")
+	router.HandleFunc("/alerts", makeHTTPHandleFunc(s.handleCreatePriceAlert)).Methods("POST")
+	router.HandleFunc("/alerts", makeHTTPHandleFunc(s.handleGetPriceAlerts)).Methods("GET")
+	router.HandleFunc("/alerts/{id}", makeHTTPHandleFunc(s.handleDeletePriceAlert)).Methods("DELETE")
+	router.HandleFunc("/wishlists", makeHTTPHandleFunc(s.handleGetWishlists)).Methods("GET")
+	router.HandleFunc("/wishlists", makeHTTPHandleFunc(s.handleCreateWishlist)).Methods("POST")
+	router.HandleFunc("/wishlists/{id}", makeHTTPHandleFunc(s.handleRenameWishlist)).Methods("PUT", "PATCH")
+	router.HandleFunc("/wishlists/{id}", makeHTTPHandleFunc(s.handleDeleteWishlist)).Methods("DELETE")
+	router.HandleFunc("/wishlists/{id}/items", makeHTTPHandleFunc(s.handleAddProductToWishlist)).Methods("POST")
+	router.HandleFunc("/wishlists/{id}/items/{productID}", makeHTTPHandleFunc(s.handleRemoveProductFromWishlist)).Methods("DELETE")
+	router.HandleFunc("/compare", makeHTTPHandleFunc(s.handleCompareProducts)).Methods("GET")
 
 	corsRouter := corsMiddleware(router)
 
@@ -42,10 +103,13 @@ func (s *APIServer) Run() {
 	http.ListenAndServe(s.listenAddr, corsRouter)
 }
 
-func NewAPIServer(listenAddr string, store Storage) *APIServer {
+func NewAPIServer(listenAddr string, store Storage, passwordPolicy *PasswordPolicy, notifier Notifier, loginLimiter *LoginLimiter) *APIServer {
 	return &APIServer{
-		listenAddr: listenAddr,
-		store:      store,
+		listenAddr:     listenAddr,
+		store:          store,
+		passwordPolicy: passwordPolicy,
+		notifier:       notifier,
+		loginLimiter:   loginLimiter,
 	}
 }
 
@@ -58,15 +122,27 @@ func (s *APIServer) handleRegister(w http.ResponseWriter, r *http.Request) error
 		return err
 	}
 
-	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	email, err := normalizeEmail(req.Email)
+	if err != nil {
+		return err
+	}
+	hashedPassword, err := s.passwordPolicy.Hash(req.Password, email)
+	if err != nil {
+		return err
+	}
 
 	user := &User{
-		Email:    req.Email,
-		Password: string(hashedPassword),
+		Email:    email,
+		Password: hashedPassword,
 	}
 	if err := s.store.CreateUser(user); err != nil {
+		if isUniqueViolation(err) {
+			return statusErrorf(http.StatusConflict, "an account with this email already exists")
+		}
 		return fmt.Errorf("error creating user: %w", err)
 	}
+	s.sendVerificationEmail(user)
+
 	return WriteJSON(w, http.StatusCreated, map[string]string{"message": "user created"})
 }
 
@@ -79,16 +155,28 @@ func (s *APIServer) handleLogin(w http.ResponseWriter, r *http.Request) error {
 		return err
 	}
 
-	user, err := s.store.GetUserByEmail(req.Email)
+	email := loginKey(req.Email)
+	ip := s.loginLimiter.clientIP(r)
+	if wait := s.loginLimiter.Reserve(email, ip); wait > 0 {
+		return tooManyLoginAttempts(w, wait)
+	}
+
+	user, err := s.store.GetUserByEmail(email)
 	if err != nil {
-		return fmt.Errorf("invalid credentials")
+		// Compare against a dummy hash so unknown emails take as long as
+		// wrong passwords.
+		bcrypt.CompareHashAndPassword(dummyPasswordHash(), []byte(req.Password))
+		s.recordFailedLogin(nil, email, ip)
+		return statusErrorf(http.StatusUnauthorized, "invalid credentials")
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
-		return fmt.Errorf("invalid credentials")
+		s.recordFailedLogin(user, email, ip)
+		return statusErrorf(http.StatusUnauthorized, "invalid credentials")
 	}
+	s.loginLimiter.Succeed(email, ip)
 
-	token, err := GenerateJWT(user.ID)
+	token, err := GenerateJWT(user)
 	if err != nil {
 		return fmt.Errorf("failed to generate token: %w", err)
 	}
@@ -133,26 +221,26 @@ func (s *APIServer) handleGetProduct(w http.ResponseWriter, r *http.Request) err
 }
 
 func (s *APIServer) handleFilteredProducts(w http.ResponseWriter, r *http.Request) error {
-	category := r.URL.Query().Get("category")
-	manufacturer := r.URL.Query().Get("manufacturer")
-	store := r.URL.Query().Get("store")
-	minPrice := r.URL.Query().Get("minPrice")
-	maxPrice := r.URL.Query().Get("maxPrice")
-	title := r.URL.Query().Get("title")
-	page := r.URL.Query().Get("page")
-	pageSize := r.URL.Query().Get("pageSize")
+	query, err := ParseProductQuery(r.URL.Query())
+	if err != nil {
+		return err
+	}
 
-	products, totalCount, err := s.store.GetFilteredProducts(category, manufacturer, store, minPrice, maxPrice, title, page, pageSize)
+	products, totalCount, nextCursor, err := s.store.GetFilteredProducts(query)
 	if err != nil {
 		return err
 	}
 
 	response := struct {
 		Data       []*Product `json:"data"`
-		TotalCount int        `json:"totalCount"`
+		TotalCount *int       `json:"totalCount,omitempty"`
+		NextCursor string     `json:"nextCursor,omitempty"`
 	}{
 		Data:       products,
-		TotalCount: totalCount,
+		NextCursor: nextCursor,
+	}
+	if !query.SkipCount {
+		response.TotalCount = &totalCount
 	}
 
 	return WriteJSON(w, http.StatusOK, response)
@@ -170,10 +258,34 @@ type APIError struct {
 	Error string
 }
 
+// StatusError is an error that carries the HTTP status it should be reported
+// with. Handlers return it when a plain 400 would be misleading.
+type StatusError struct {
+	Status int
+	Err    error
+}
+
+func (e *StatusError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *StatusError) Unwrap() error {
+	return e.Err
+}
+
+func statusErrorf(status int, format string, args ...any) error {
+	return &StatusError{Status: status, Err: fmt.Errorf(format, args...)}
+}
+
 func makeHTTPHandleFunc(f apiFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if err := f(w, r); err != nil {
-			WriteJSON(w, http.StatusBadRequest, APIError{Error: err.Error()})
+			status := http.StatusBadRequest
+			var statusErr *StatusError
+			if errors.As(err, &statusErr) {
+				status = statusErr.Status
+			}
+			WriteJSON(w, status, APIError{Error: err.Error()})
 		}
 	}
 }
@@ -184,7 +296,7 @@ func corsMiddleware(next http.Handler) http.Handler {
 		if origin == "http://pcpartsmk.store" || origin == "http://pcpartsmk.store:80" {
 			w.Header().Set("Access-Control-Allow-Origin", origin)
 		}
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
 		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 
 		if r.Method == "OPTIONS" {
@@ -228,31 +340,19 @@ func (s *APIServer) handleImageProxy(w http.ResponseWriter, r *http.Request) err
 	return copyErr
 }
 
+// handleGetManufacturers returns the brand directory with product counts.
+// It accepts the product filters, so ?category= scopes it as before.
 func (s *APIServer) handleGetManufacturers(w http.ResponseWriter, r *http.Request) error {
-	category := r.URL.Query().Get("category")
-
-	var manufacturers []string
-	var err error
-
-	if category != "" {
-		manufacturers, err = s.store.GetManufacturersByCategory(category)
-	} else {
-		manufacturers, err = s.store.GetUniqueManufacturers()
-	}
-
+	q, err := ParseProductQuery(r.URL.Query())
 	if err != nil {
-		return fmt.Errorf("failed to fetch manufacturers: %w", err)
+		return err
 	}
 
-	return WriteJSON(w, http.StatusOK, manufacturers)
-}
-
-func (s *APIServer) handleGetStores(w http.ResponseWriter, r *http.Request) error {
-	stores, err := s.store.GetUniqueStores()
+	brands, err := s.store.GetBrands(q)
 	if err != nil {
-		return fmt.Errorf("failed to fetch stores: %w", err)
+		return fmt.Errorf("failed to fetch manufacturers: %w", err)
 	}
-	return WriteJSON(w, http.StatusOK, stores)
+	return WriteJSON(w, http.StatusOK, brands)
 }
 
 func handleYouTubeSearch(w http.ResponseWriter, r *http.Request) {
@@ -300,6 +400,11 @@ func handleYouTubeSearch(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *APIServer) handleCreateConfiguration(w http.ResponseWriter, r *http.Request) error {
+	userID, err := s.userIDFromRequest(r)
+	if err != nil {
+		return err
+	}
+
 	var req struct {
 		UserID int    `json:"userID"`
 		Name   string `json:"name"`
@@ -307,8 +412,15 @@ func (s *APIServer) handleCreateConfiguration(w http.ResponseWriter, r *http.Req
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		return err
 	}
+	if req.UserID != 0 && req.UserID != userID {
+		return statusErrorf(http.StatusForbidden, "cannot create configurations for another user")
+	}
+	name, err := validateConfigurationName(req.Name)
+	if err != nil {
+		return err
+	}
 
-	configID, err := s.store.CreateConfiguration(req.UserID, req.Name)
+	configID, err := s.store.CreateConfiguration(userID, name)
 	if err != nil {
 		return fmt.Errorf("failed to create configuration: %w", err)
 	}
@@ -316,50 +428,245 @@ func (s *APIServer) handleCreateConfiguration(w http.ResponseWriter, r *http.Req
 	return WriteJSON(w, http.StatusCreated, map[string]int{"configID": configID})
 }
 
+func (s *APIServer) handleGetConfiguration(w http.ResponseWriter, r *http.Request) error {
+	config, err := s.getOwnedConfiguration(r)
+	if err != nil {
+		return err
+	}
+	return WriteJSON(w, http.StatusOK, config)
+}
+
+func (s *APIServer) handleRenameConfiguration(w http.ResponseWriter, r *http.Request) error {
+	config, err := s.getOwnedConfiguration(r)
+	if err != nil {
+		return err
+	}
+
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return err
+	}
+	name, err := validateConfigurationName(req.Name)
+	if err != nil {
+		return err
+	}
+
+	if err := s.store.RenameConfiguration(config.ID, name); err != nil {
+		return fmt.Errorf("failed to rename configuration: %w", err)
+	}
+
+	config.Name = name
+	return WriteJSON(w, http.StatusOK, config)
+}
+
+func (s *APIServer) handleDeleteConfiguration(w http.ResponseWriter, r *http.Request) error {
+	config, err := s.getOwnedConfiguration(r)
+	if err != nil {
+		return err
+	}
+
+	if err := s.store.DeleteConfiguration(config.ID); err != nil {
+		return fmt.Errorf("failed to delete configuration: %w", err)
+	}
+
+	return WriteJSON(w, http.StatusOK, map[string]string{"message": "configuration deleted"})
+}
+
+func (s *APIServer) handleDuplicateConfiguration(w http.ResponseWriter, r *http.Request) error {
+	config, err := s.getOwnedConfiguration(r)
+	if err != nil {
+		return err
+	}
+
+	var req struct {
+		Name string `json:"name"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return err
+		}
+	}
+	if req.Name == "" {
+		req.Name = copyName(config.Name)
+	}
+	name, err := validateConfigurationName(req.Name)
+	if err != nil {
+		return err
+	}
+
+	configID, err := s.store.DuplicateConfiguration(config.ID, config.UserID, name, "duplicate")
+	if err != nil {
+		return fmt.Errorf("failed to duplicate configuration: %w", err)
+	}
+
+	return WriteJSON(w, http.StatusCreated, map[string]int{"configID": configID})
+}
+
 func (s *APIServer) handleAddProductToConfiguration(w http.ResponseWriter, r *http.Request) error {
-	vars := mux.Vars(r)
-	configIDStr := vars["id"]
-	var configID int
-	_, err := fmt.Sscanf(configIDStr, "%d", &configID)
+	config, err := s.getOwnedConfiguration(r)
 	if err != nil {
-		return fmt.Errorf("invalid configuration ID")
+		return err
 	}
 
 	var req struct {
-		ProductID int `json:"productID"`
+		ProductID int    `json:"productID"`
+		Quantity  int    `json:"quantity"`
+		Slot      string `json:"slot"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		return err
 	}
+	if req.Quantity == 0 {
+		req.Quantity = 1
+	}
+	if err := validateQuantity(req.Quantity); err != nil {
+		return err
+	}
+	slot, err := validateSlot(req.Slot)
+	if err != nil {
+		return err
+	}
 
-	if err := s.store.AddProductToConfiguration(configID, req.ProductID); err != nil {
+	if err := s.store.AddProductToConfiguration(config.ID, req.ProductID, req.Quantity, slot, config.UserID, "add_product"); err != nil {
 		return fmt.Errorf("failed to add product to configuration: %w", err)
 	}
 
 	return WriteJSON(w, http.StatusOK, map[string]string{"message": "product added"})
 }
 
-func (s *APIServer) handleRemoveProductFromConfiguration(w http.ResponseWriter, r *http.Request) error {
-	vars := mux.Vars(r)
-	configIDStr := vars["id"]
-	productIDStr := vars["productID"]
-	var configID, productID int
-	_, err := fmt.Sscanf(configIDStr, "%d", &configID)
+func (s *APIServer) handleSetConfigurationSlot(w http.ResponseWriter, r *http.Request) error {
+	config, err := s.getOwnedConfiguration(r)
 	if err != nil {
-		return fmt.Errorf("invalid configuration ID")
+		return err
 	}
-	_, err = fmt.Sscanf(productIDStr, "%d", &productID)
+
+	slot, err := validateSlot(mux.Vars(r)["slot"])
 	if err != nil {
+		return err
+	}
+
+	var req struct {
+		ProductID int `json:"productID"`
+		Quantity  int `json:"quantity"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return err
+	}
+	if req.Quantity == 0 {
+		req.Quantity = 1
+	}
+	if err := validateQuantity(req.Quantity); err != nil {
+		return err
+	}
+
+	if err := s.store.AddProductToConfiguration(config.ID, req.ProductID, req.Quantity, slot, config.UserID, "set_slot"); err != nil {
+		return fmt.Errorf("failed to set configuration slot: %w", err)
+	}
+
+	return WriteJSON(w, http.StatusOK, map[string]string{"message": "slot updated"})
+}
+
+func (s *APIServer) handleSetConfigurationItemQuantity(w http.ResponseWriter, r *http.Request) error {
+	config, err := s.getOwnedConfiguration(r)
+	if err != nil {
+		return err
+	}
+
+	var itemID int
+	if _, err := fmt.Sscanf(mux.Vars(r)["itemID"], "%d", &itemID); err != nil {
+		return fmt.Errorf("invalid item ID")
+	}
+
+	var req struct {
+		Quantity int `json:"quantity"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return err
+	}
+	if err := validateQuantity(req.Quantity); err != nil {
+		return err
+	}
+
+	if err := s.store.SetConfigurationItemQuantity(config.ID, itemID, req.Quantity, config.UserID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return statusErrorf(http.StatusNotFound, "configuration item not found")
+		}
+		return fmt.Errorf("failed to update quantity: %w", err)
+	}
+
+	return WriteJSON(w, http.StatusOK, map[string]string{"message": "quantity updated"})
+}
+
+func (s *APIServer) handleRemoveConfigurationItem(w http.ResponseWriter, r *http.Request) error {
+	config, err := s.getOwnedConfiguration(r)
+	if err != nil {
+		return err
+	}
+
+	var itemID int
+	if _, err := fmt.Sscanf(mux.Vars(r)["itemID"], "%d", &itemID); err != nil {
+		return fmt.Errorf("invalid item ID")
+	}
+
+	if err := s.store.RemoveConfigurationItem(config.ID, itemID, config.UserID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return statusErrorf(http.StatusNotFound, "configuration item not found")
+		}
+		return fmt.Errorf("failed to remove item: %w", err)
+	}
+
+	return WriteJSON(w, http.StatusOK, map[string]string{"message": "item removed"})
+}
+
+func (s *APIServer) handleRemoveProductFromConfiguration(w http.ResponseWriter, r *http.Request) error {
+	config, err := s.getOwnedConfiguration(r)
+	if err != nil {
+		return err
+	}
+
+	var productID int
+	if _, err := fmt.Sscanf(mux.Vars(r)["productID"], "%d", &productID); err != nil {
 		return fmt.Errorf("invalid product ID")
 	}
 
-	if err := s.store.RemoveProductFromConfiguration(configID, productID); err != nil {
+	if err := s.store.RemoveProductFromConfiguration(config.ID, productID, config.UserID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return statusErrorf(http.StatusNotFound, "product is not part of this configuration")
+		}
 		return fmt.Errorf("failed to remove product from configuration: %w", err)
 	}
 
 	return WriteJSON(w, http.StatusOK, map[string]string{"message": "product removed"})
 }
 
+// getOwnedConfiguration loads the configuration named by the {id} route
+// variable and checks that it belongs to the authenticated user.
+func (s *APIServer) getOwnedConfiguration(r *http.Request) (*ComputerConfiguration, error) {
+	userID, err := s.userIDFromRequest(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var configID int
+	if _, err := fmt.Sscanf(mux.Vars(r)["id"], "%d", &configID); err != nil {
+		return nil, fmt.Errorf("invalid configuration ID")
+	}
+
+	config, err := s.store.GetConfigurationByID(configID)
+	if err != nil {
+		return nil, fmt.Errorf("could not get configuration: %w", err)
+	}
+	if config == nil {
+		return nil, statusErrorf(http.StatusNotFound, "configuration not found")
+	}
+	if config.UserID != userID {
+		return nil, statusErrorf(http.StatusForbidden, "configuration belongs to another user")
+	}
+	return config, nil
+}
+
 func (s *APIServer) handleGetConfigurationsByUser(w http.ResponseWriter, r *http.Request) error {
 	vars := mux.Vars(r)
 	userIDStr := vars["userID"]
@@ -374,6 +681,17 @@ func (s *APIServer) handleGetConfigurationsByUser(w http.ResponseWriter, r *http
 		return fmt.Errorf("could not get configurations: %w", err)
 	}
 
+	// Other visitors only see the builds the user chose to publish.
+	if requesterID, err := s.userIDFromRequest(r); err != nil || requesterID != userID {
+		public := []*ComputerConfiguration{}
+		for _, c := range configs {
+			if c.Visibility == VisibilityPublic {
+				public = append(public, c)
+			}
+		}
+		configs = public
+	}
+
 	return WriteJSON(w, http.StatusOK, configs)
 }
 
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+)
+
+const (
+	AuditAccountLocked  = "account_locked"
+	AuditRoleChanged    = "role_changed"
+	AuditProductChanged = "product_changed"
+)
+
+func (s *PostgressStore) CreateAuditLogTable() error {
+	_, err := s.db.Exec(`
+    CREATE TABLE IF NOT EXISTS audit_log (
+        id SERIAL PRIMARY KEY,
+        event TEXT NOT NULL,
+        user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+        email TEXT NOT NULL DEFAULT '',
+        ip TEXT NOT NULL DEFAULT '',
+        details JSONB NOT NULL DEFAULT '{}',
+        created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+    );
+    CREATE INDEX IF NOT EXISTS audit_log_event_idx ON audit_log (event, created_at);
+    `)
+	return err
+}
+
+func (s *PostgressStore) RecordAuditEvent(e *AuditEvent) error {
+	details, err := json.Marshal(e.Details)
+	if err != nil {
+		return err
+	}
+	if e.Details == nil {
+		details = []byte("{}")
+	}
+	return s.db.QueryRow(`
+        INSERT INTO audit_log (event, user_id, email, ip, details)
+        VALUES ($1, $2, $3, $4, $5)
+        RETURNING id, created_at
+    `, e.Event, e.UserID, e.Email, e.IP, details).Scan(&e.ID, &e.CreatedAt)
+}
+
+// recordAuditEvent writes an audit entry for an action that has already
+// happened, so failures are only logged.
+func (s *APIServer) recordAuditEvent(e *AuditEvent) {
+	if err := s.store.RecordAuditEvent(e); err != nil {
+		log.Printf("failed to record %s audit event: %v", e.Event, err)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	maxEmailLength           = 254
+	defaultMinPasswordLength = 8
+	// bcrypt ignores everything past 72 bytes.
+	maxPasswordBytes = 72
+)
+
+// normalizeEmail trims and lower-cases an email address and checks that it
+// is a plain address such as "user@example.com".
+func normalizeEmail(email string) (string, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if email == "" || len(email) > maxEmailLength {
+		return "", statusErrorf(http.StatusUnprocessableEntity, "invalid email address")
+	}
+
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email || addr.Name != "" {
+		return "", statusErrorf(http.StatusUnprocessableEntity, "invalid email address")
+	}
+	_, domain, _ := strings.Cut(email, "@")
+	if !strings.Contains(domain, ".") || strings.HasPrefix(domain, ".") || strings.HasSuffix(domain, ".") {
+		return "", statusErrorf(http.StatusUnprocessableEntity, "invalid email address")
+	}
+	return email, nil
+}
+
+// PasswordPolicy decides which passwords users may choose.
+type PasswordPolicy struct {
+	MinLength int
+	breached  map[string]bool
+}
+
+// LoadPasswordPolicyFromEnv reads PASSWORD_MIN_LENGTH and the optional
+// BREACHED_PASSWORDS_FILE, a list of known leaked passwords, one per line.
+func LoadPasswordPolicyFromEnv() (*PasswordPolicy, error) {
+	policy := &PasswordPolicy{MinLength: defaultMinPasswordLength, breached: map[string]bool{}}
+
+	if v := os.Getenv("PASSWORD_MIN_LENGTH"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxPasswordBytes {
+			return nil, fmt.Errorf("invalid PASSWORD_MIN_LENGTH %q", v)
+		}
+		policy.MinLength = n
+	}
+
+	path := os.Getenv("BREACHED_PASSWORDS_FILE")
+	if path == "" {
+		return policy, nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open breached passwords file: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			policy.breached[strings.ToLower(line)] = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("could not read breached passwords file: %w", err)
+	}
+	return policy, nil
+}
+
+// Check returns an error describing why password is not acceptable for the
+// account with the given email.
+func (p *PasswordPolicy) Check(password, email string) error {
+	if len([]rune(password)) < p.MinLength {
+		return statusErrorf(http.StatusUnprocessableEntity, "password must be at least %d characters", p.MinLength)
+	}
+	if len(password) > maxPasswordBytes {
+		return statusErrorf(http.StatusUnprocessableEntity, "password must be at most %d bytes", maxPasswordBytes)
+	}
+	if strings.EqualFold(password, email) {
+		return statusErrorf(http.StatusUnprocessableEntity, "password must not be the same as the email address")
+	}
+	if p.breached[strings.ToLower(password)] {
+		return statusErrorf(http.StatusUnprocessableEntity, "this password has appeared in a data breach, please choose another")
+	}
+	return nil
+}
+
+// Hash checks password against the policy and returns its bcrypt hash.
+func (p *PasswordPolicy) Hash(password, email string) (string, error) {
+	if err := p.Check(password, email); err != nil {
+		return "", err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("error hashing password: %w", err)
+	}
+	return string(hash), nil
+}
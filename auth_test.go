@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeEmail(t *testing.T) {
+	valid := map[string]string{
+		"user@example.com":         "user@example.com",
+		"  User@Example.COM ":      "user@example.com",
+		"first.last+tag@mail.mk":   "first.last+tag@mail.mk",
+		"a@sub.domain.example.org": "a@sub.domain.example.org",
+	}
+	for in, want := range valid {
+		if got, err := normalizeEmail(in); err != nil || got != want {
+			t.Errorf("normalizeEmail(%q) = %q, %v, want %q", in, got, err, want)
+		}
+	}
+
+	invalid := []string{
+		"",
+		"user",
+		"user@localhost",
+		"user@.example.com",
+		"user@example.com.",
+		"User <user@example.com>",
+		"user@@example.com",
+		strings.Repeat("a", maxEmailLength) + "@example.com",
+	}
+	for _, in := range invalid {
+		_, err := normalizeEmail(in)
+		var statusErr *StatusError
+		if !errors.As(err, &statusErr) || statusErr.Status != http.StatusUnprocessableEntity {
+			t.Errorf("normalizeEmail(%q) err = %v, want 422", in, err)
+		}
+	}
+}
+
+func TestPasswordPolicyCheck(t *testing.T) {
+	policy := &PasswordPolicy{MinLength: 8, breached: map[string]bool{"password123": true}}
+
+	tests := []struct {
+		password string
+		ok       bool
+	}{
+		{"correct horse", true},
+		{"шифра123", true},
+		{"short", false},
+		{"шифра12", false},
+		{strings.Repeat("a", maxPasswordBytes), true},
+		{strings.Repeat("a", maxPasswordBytes+1), false},
+		{"User@Example.com", false},
+		{"Password123", false},
+	}
+	for _, tt := range tests {
+		err := policy.Check(tt.password, "user@example.com")
+		if tt.ok && err != nil {
+			t.Errorf("Check(%q) = %v, want nil", tt.password, err)
+		}
+		var statusErr *StatusError
+		if !tt.ok && (!errors.As(err, &statusErr) || statusErr.Status != http.StatusUnprocessableEntity) {
+			t.Errorf("Check(%q) = %v, want 422", tt.password, err)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Availability values of a listing.
+const (
+	AvailabilityInStock    = "in_stock"
+	AvailabilityLimited    = "limited"
+	AvailabilityOnOrder    = "on_order"
+	AvailabilityOutOfStock = "out_of_stock"
+	AvailabilityUnknown    = "unknown"
+)
+
+var availabilities = []string{
+	AvailabilityInStock, AvailabilityLimited, AvailabilityOnOrder, AvailabilityOutOfStock, AvailabilityUnknown,
+}
+
+// purchasableAvailabilities are the values the inStock filter keeps.
+var purchasableAvailabilities = []string{AvailabilityInStock, AvailabilityLimited}
+
+// availabilityWarnings explains why a configuration item cannot be bought
+// right away.
+var availabilityWarnings = map[string]string{
+	AvailabilityOutOfStock: "out of stock",
+	AvailabilityOnOrder:    "available on order only",
+}
+
+// availabilityPhrases maps what stores write in their feeds, in Macedonian
+// and English, to an availability. Out of stock phrases and negations come
+// first because "нема на залиха" contains "на залиха", "недостапно" contains
+// "достапно" and "not available" contains "available".
+var availabilityPhrases = []struct {
+	phrase       string
+	availability string
+}{
+	{"нема", AvailabilityOutOfStock},
+	{"распродад", AvailabilityOutOfStock},
+	{"out of stock", AvailabilityOutOfStock},
+	{"sold out", AvailabilityOutOfStock},
+	{"unavailable", AvailabilityOutOfStock},
+	{"недостап", AvailabilityOutOfStock},
+	{"no stock", AvailabilityOutOfStock},
+	{"not ", AvailabilityOutOfStock},
+	{"по нарачка", AvailabilityOnOrder},
+	{"нарачка", AvailabilityOnOrder},
+	{"on order", AvailabilityOnOrder},
+	{"backorder", AvailabilityOnOrder},
+	{"pre-order", AvailabilityOnOrder},
+	{"preorder", AvailabilityOnOrder},
+	{"ограничен", AvailabilityLimited},
+	{"последн", AvailabilityLimited},
+	{"limited", AvailabilityLimited},
+	{"low stock", AvailabilityLimited},
+	{"на залиха", AvailabilityInStock},
+	{"достапно", AvailabilityInStock},
+	{"in stock", AvailabilityInStock},
+	{"available", AvailabilityInStock},
+}
+
+// maxLimitedStock is the largest quantity a feed can report that still
+// counts as limited stock.
+const maxLimitedStock = 3
+
+// parseAvailability reads the availability column of a store feed, which is
+// either one of our own values, a phrase or a quantity.
+func parseAvailability(raw string) string {
+	v := strings.ToLower(strings.TrimSpace(raw))
+	if slices.Contains(availabilities, v) {
+		return v
+	}
+	if n, err := strconv.Atoi(v); err == nil {
+		switch {
+		case n <= 0:
+			return AvailabilityOutOfStock
+		case n <= maxLimitedStock:
+			return AvailabilityLimited
+		default:
+			return AvailabilityInStock
+		}
+	}
+	for _, p := range availabilityPhrases {
+		if strings.Contains(v, p.phrase) {
+			return p.availability
+		}
+	}
+	return AvailabilityUnknown
+}
+
+func validateAvailability(availability string) error {
+	if !slices.Contains(availabilities, availability) {
+		return statusErrorf(http.StatusUnprocessableEntity, "availability must be one of %s", strings.Join(availabilities, ", "))
+	}
+	return nil
+}
+
+func (s *PostgressStore) CreateStockHistoryTable() error {
+	_, err := s.db.Exec(`
+    CREATE TABLE IF NOT EXISTS product_stock_history (
+        id SERIAL PRIMARY KEY,
+        product_id INTEGER NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+        availability TEXT NOT NULL,
+        price BIGINT NOT NULL,
+        recorded_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+    );
+    CREATE INDEX IF NOT EXISTS product_stock_history_product_idx ON product_stock_history (product_id, recorded_at);
+    `)
+	return err
+}
+
+// RecordStockSnapshot stores the availability and price of every listing
+// whose stock or price differs from its latest snapshot, so the history only
+// grows when something changes.
+func (s *PostgressStore) RecordStockSnapshot() error {
+	_, err := s.db.Exec(`
+        INSERT INTO product_stock_history (product_id, availability, price)
+        SELECT p.id, p.availability, COALESCE(p.price, 0)
+        FROM products p
+        LEFT JOIN LATERAL (
+            SELECT availability, price FROM product_stock_history h
+            WHERE h.product_id = p.id
+            ORDER BY h.recorded_at DESC, h.id DESC
+            LIMIT 1
+        ) last ON true
+        WHERE last.availability IS DISTINCT FROM p.availability
+           OR last.price IS DISTINCT FROM COALESCE(p.price, 0)
+    `)
+	return err
+}
+
+// GetStockHistory returns the snapshots of a listing, oldest first.
+func (s *PostgressStore) GetStockHistory(productID int) ([]*StockSnapshot, error) {
+	rows, err := s.db.Query(`
+        SELECT availability, price, recorded_at
+        FROM product_stock_history
+        WHERE product_id = $1
+        ORDER BY recorded_at, id
+    `, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := []*StockSnapshot{}
+	for rows.Next() {
+		snapshot := new(StockSnapshot)
+		if err := rows.Scan(&snapshot.Availability, &snapshot.Price, &snapshot.RecordedAt); err != nil {
+			return nil, err
+		}
+		history = append(history, snapshot)
+	}
+	return history, rows.Err()
+}
+
+func (s *APIServer) handleGetStockHistory(w http.ResponseWriter, r *http.Request) error {
+	id, err := productIDFromRequest(r)
+	if err != nil {
+		return err
+	}
+	product, err := s.store.GetProductByID(id)
+	if err != nil {
+		return fmt.Errorf("could not fetch product: %w", err)
+	}
+	if product == nil {
+		return statusErrorf(http.StatusNotFound, "product not found")
+	}
+
+	history, err := s.store.GetStockHistory(id)
+	if err != nil {
+		return fmt.Errorf("could not fetch stock history: %w", err)
+	}
+	return WriteJSON(w, http.StatusOK, history)
+}
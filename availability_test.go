@@ -0,0 +1,42 @@
+package main
+
+import "testing"
+
+func TestParseAvailability(t *testing.T) {
+	tests := map[string]string{
+		"in_stock":         AvailabilityInStock,
+		" ON_ORDER ":       AvailabilityOnOrder,
+		"0":                AvailabilityOutOfStock,
+		"-2":               AvailabilityOutOfStock,
+		"3":                AvailabilityLimited,
+		"25":               AvailabilityInStock,
+		"Нема на залиха":   AvailabilityOutOfStock,
+		"На залиха":        AvailabilityInStock,
+		"По нарачка":       AvailabilityOnOrder,
+		"Последни парчиња": AvailabilityLimited,
+		"Sold out":         AvailabilityOutOfStock,
+		"Available":        AvailabilityInStock,
+		"Недостапно":       AvailabilityOutOfStock,
+		"Not available":    AvailabilityOutOfStock,
+		"not in stock":     AvailabilityOutOfStock,
+		"No stock":         AvailabilityOutOfStock,
+		"":                 AvailabilityUnknown,
+		"call us":          AvailabilityUnknown,
+	}
+	for raw, want := range tests {
+		if got := parseAvailability(raw); got != want {
+			t.Errorf("parseAvailability(%q) = %q, want %q", raw, got, want)
+		}
+	}
+}
+
+func TestValidateAvailability(t *testing.T) {
+	for _, a := range availabilities {
+		if err := validateAvailability(a); err != nil {
+			t.Errorf("validateAvailability(%q) = %v", a, err)
+		}
+	}
+	if err := validateAvailability("maybe"); err == nil {
+		t.Error("unknown availability accepted")
+	}
+}
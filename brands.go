@@ -0,0 +1,458 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// defaultBrands seeds the brand directory with the manufacturers stores
+// spell in several ways. The brand name itself is always an alias too.
+var defaultBrands = []struct {
+	name, website string
+	aliases       []string
+}{
+	{"ASUS", "https://www.asus.com", []string{"asustek", "asustek computer", "asus rog", "rog"}},
+	{"MSI", "https://www.msi.com", []string{"micro-star", "micro star", "micro-star international"}},
+	{"Gigabyte", "https://www.gigabyte.com", []string{"aorus", "gigabyte technology"}},
+	{"ASRock", "https://www.asrock.com", nil},
+	{"AMD", "https://www.amd.com", []string{"advanced micro devices"}},
+	{"Intel", "https://www.intel.com", []string{"intel corporation"}},
+	{"NVIDIA", "https://www.nvidia.com", nil},
+	{"Corsair", "https://www.corsair.com", nil},
+	{"Kingston", "https://www.kingston.com", []string{"kingston technology", "kingston fury"}},
+	{"Samsung", "https://www.samsung.com", nil},
+	{"Western Digital", "https://www.westerndigital.com", []string{"wd", "wdc"}},
+	{"Seagate", "https://www.seagate.com", nil},
+	{"Crucial", "https://www.crucial.com", []string{"micron"}},
+	{"ADATA", "https://www.adata.com", []string{"xpg", "a-data"}},
+	{"G.Skill", "https://www.gskill.com", []string{"gskill", "g skill"}},
+	{"TeamGroup", "https://www.teamgroupinc.com", []string{"team group", "team", "t-force"}},
+	{"be quiet!", "https://www.bequiet.com", []string{"be quiet", "bequiet"}},
+	{"Cooler Master", "https://www.coolermaster.com", []string{"coolermaster"}},
+	{"Noctua", "https://noctua.at", nil},
+	{"Arctic", "https://www.arctic.de", []string{"arctic cooling"}},
+	{"DeepCool", "https://www.deepcool.com", []string{"deep cool"}},
+	{"NZXT", "https://nzxt.com", nil},
+	{"Thermaltake", "https://www.thermaltake.com", []string{"tt"}},
+	{"Fractal Design", "https://www.fractal-design.com", []string{"fractal"}},
+	{"Lian Li", "https://lian-li.com", []string{"lianli", "lian-li"}},
+	{"Seasonic", "https://seasonic.com", []string{"sea sonic"}},
+	{"Sapphire", "https://www.sapphiretech.com", nil},
+	{"Palit", "https://www.palit.com", nil},
+	{"Zotac", "https://www.zotac.com", nil},
+	{"PNY", "https://www.pny.com", nil},
+	{"LG", "https://www.lg.com", []string{"lg electronics"}},
+	{"Dell", "https://www.dell.com", nil},
+	{"AOC", "https://aoc.com", nil},
+	{"BenQ", "https://www.benq.com", nil},
+	{"Logitech", "https://www.logitech.com", []string{"logitech g"}},
+	{"Razer", "https://www.razer.com", nil},
+}
+
+var (
+	brandAliasSpaces = regexp.MustCompile(`\s+`)
+	slugInvalid      = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+// normalizeBrandAlias is how manufacturer names are compared with aliases.
+// brandAliasSQL must stay equivalent.
+func normalizeBrandAlias(name string) string {
+	return brandAliasSpaces.ReplaceAllString(strings.ToLower(strings.TrimSpace(name)), " ")
+}
+
+const brandAliasSQL = `LOWER(REGEXP_REPLACE(TRIM(manufacturer), '\s+', ' ', 'g'))`
+
+// slugify turns a name into a URL slug, transliterating Macedonian
+// Cyrillic. It returns fallback when nothing usable is left.
+func slugify(name, fallback string) string {
+	slug := strings.Trim(slugInvalid.ReplaceAllString(cyrillicToLatin.Replace(strings.ToLower(name)), "-"), "-")
+	if slug == "" {
+		return fallback
+	}
+	return slug
+}
+
+var cyrillicToLatin = strings.NewReplacer(
+	"а", "a", "б", "b", "в", "v", "г", "g", "д", "d", "ѓ", "gj", "е", "e", "ж", "zh", "з", "z", "ѕ", "dz",
+	"и", "i", "ј", "j", "к", "k", "л", "l", "љ", "lj", "м", "m", "н", "n", "њ", "nj", "о", "o", "п", "p",
+	"р", "r", "с", "s", "т", "t", "ќ", "kj", "у", "u", "ф", "f", "х", "h", "ц", "c", "ч", "ch", "џ", "dj", "ш", "sh",
+)
+
+func (s *PostgressStore) CreateBrandTables() error {
+	_, err := s.db.Exec(`
+    CREATE TABLE IF NOT EXISTS brands (
+        id SERIAL PRIMARY KEY,
+        slug TEXT NOT NULL UNIQUE,
+        name TEXT NOT NULL,
+        logo TEXT NOT NULL DEFAULT '',
+        website TEXT NOT NULL DEFAULT ''
+    );
+    CREATE TABLE IF NOT EXISTS brand_aliases (
+        alias TEXT PRIMARY KEY,
+        brand_id INTEGER NOT NULL REFERENCES brands(id) ON DELETE CASCADE
+    );
+    ALTER TABLE products ADD COLUMN IF NOT EXISTS brand_id INTEGER REFERENCES brands(id) ON DELETE SET NULL;
+    CREATE INDEX IF NOT EXISTS products_brand_id_idx ON products (brand_id);
+    `)
+	if err != nil {
+		return err
+	}
+
+	// A brand merged into another leaves its name behind as an alias, so a
+	// seeded name that is already an alias adds to that brand instead of
+	// recreating the one an admin merged away.
+	for _, b := range defaultBrands {
+		var id int
+		err := s.db.QueryRow(`
+            WITH seeded AS (
+                INSERT INTO brands (slug, name, website)
+                SELECT $1, $2, $3
+                WHERE NOT EXISTS (SELECT 1 FROM brand_aliases WHERE alias = $4)
+                ON CONFLICT (slug) DO UPDATE SET slug = EXCLUDED.slug
+                RETURNING id
+            )
+            SELECT id FROM seeded
+            UNION ALL
+            SELECT brand_id FROM brand_aliases WHERE alias = $4
+        `, slugify(b.name, "brand"), b.name, b.website, normalizeBrandAlias(b.name)).Scan(&id)
+		if err != nil {
+			return fmt.Errorf("could not seed brand %s: %w", b.name, err)
+		}
+		for _, alias := range append([]string{b.name}, b.aliases...) {
+			if _, err := s.db.Exec(`
+                INSERT INTO brand_aliases (alias, brand_id) VALUES ($1, $2)
+                ON CONFLICT (alias) DO NOTHING
+            `, normalizeBrandAlias(alias), id); err != nil {
+				return fmt.Errorf("could not seed alias %s: %w", alias, err)
+			}
+		}
+	}
+	return nil
+}
+
+const brandColumns = "b.id, b.slug, b.name, b.logo, b.website"
+
+func scanIntoBrand(row rowScanner, extra ...any) (*Brand, error) {
+	b := new(Brand)
+	err := row.Scan(append([]any{&b.ID, &b.Slug, &b.Name, &b.Logo, &b.Website}, extra...)...)
+	return b, err
+}
+
+// GetBrands returns the brands of the products matching q with their
+// product counts, largest first.
+func (s *PostgressStore) GetBrands(q *ProductQuery) ([]*Brand, error) {
+	filter := q.where()
+	rows, err := s.db.Query(`
+        SELECT `+brandColumns+`, counts.n
+        FROM brands b
+        JOIN (SELECT brand_id, COUNT(*) AS n FROM products`+filter.query+` GROUP BY brand_id) counts
+          ON counts.brand_id = b.id
+        ORDER BY counts.n DESC, b.name
+    `, filter.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	brands := []*Brand{}
+	for rows.Next() {
+		var count int
+		b, err := scanIntoBrand(rows, &count)
+		if err != nil {
+			return nil, err
+		}
+		b.ProductCount = count
+		brands = append(brands, b)
+	}
+	return brands, rows.Err()
+}
+
+func (s *PostgressStore) GetBrandByID(id int) (*Brand, error) {
+	brand, err := scanIntoBrand(s.db.QueryRow("SELECT "+brandColumns+" FROM brands b WHERE b.id = $1", id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(`SELECT alias FROM brand_aliases WHERE brand_id = $1 ORDER BY alias`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	brand.Aliases = []string{}
+	for rows.Next() {
+		var alias string
+		if err := rows.Scan(&alias); err != nil {
+			return nil, err
+		}
+		brand.Aliases = append(brand.Aliases, alias)
+	}
+	return brand, rows.Err()
+}
+
+// UpdateBrand saves the name, logo and website of b. It returns
+// sql.ErrNoRows when the brand does not exist.
+func (s *PostgressStore) UpdateBrand(b *Brand) error {
+	res, err := s.db.Exec(`
+        UPDATE brands SET name = $2, logo = $3, website = $4 WHERE id = $1
+    `, b.ID, b.Name, b.Logo, b.Website)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// SetBrandAlias points alias at the brand, taking it over from any other
+// brand.
+func (s *PostgressStore) SetBrandAlias(brandID int, alias string) error {
+	_, err := s.db.Exec(`
+        INSERT INTO brand_aliases (alias, brand_id) VALUES ($1, $2)
+        ON CONFLICT (alias) DO UPDATE SET brand_id = EXCLUDED.brand_id
+    `, alias, brandID)
+	return err
+}
+
+// MergeBrands moves the aliases and products of the brand with ID from to
+// the brand with ID into and deletes it.
+func (s *PostgressStore) MergeBrands(into, from int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`UPDATE brand_aliases SET brand_id = $1 WHERE brand_id = $2`, into, from); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE products SET brand_id = $1 WHERE brand_id = $2`, into, from); err != nil {
+		return err
+	}
+	res, err := tx.Exec(`DELETE FROM brands WHERE id = $1`, from)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return tx.Commit()
+}
+
+// GetUnknownManufacturers returns manufacturer names, as first spelled by a
+// store, that no brand alias covers yet. productIDs limits it to the names
+// of those products; nil means the whole catalog.
+func (s *PostgressStore) GetUnknownManufacturers(productIDs []int) ([]string, error) {
+	rows, err := s.db.Query(`
+        SELECT DISTINCT ON (`+brandAliasSQL+`) TRIM(manufacturer)
+        FROM products
+        WHERE TRIM(COALESCE(manufacturer, '')) <> ''
+          AND `+brandAliasSQL+` NOT IN (SELECT alias FROM brand_aliases)
+          AND ($1::int[] IS NULL OR id = ANY($1))
+        ORDER BY `+brandAliasSQL+`, id
+    `, productIDArray(productIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+// CreateBrand adds a brand named after a manufacturer, with its name as the
+// first alias. Slug clashes are resolved by numbering.
+func (s *PostgressStore) CreateBrand(name string) (*Brand, error) {
+	b := &Brand{Name: name}
+	base := slugify(name, "brand")
+	for n := 1; ; n++ {
+		b.Slug = base
+		if n > 1 {
+			b.Slug = fmt.Sprintf("%s-%d", base, n)
+		}
+		err := s.db.QueryRow(`
+            INSERT INTO brands (slug, name) VALUES ($1, $2)
+            ON CONFLICT (slug) DO NOTHING
+            RETURNING id
+        `, b.Slug, b.Name).Scan(&b.ID)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		break
+	}
+	return b, s.SetBrandAlias(b.ID, normalizeBrandAlias(name))
+}
+
+// ApplyBrandAliases links products to the brand their manufacturer name is
+// an alias of. productIDs limits it to those products; nil means the whole
+// catalog.
+func (s *PostgressStore) ApplyBrandAliases(productIDs []int) error {
+	_, err := s.db.Exec(`
+        UPDATE products p SET brand_id = a.brand_id
+        FROM brand_aliases a
+        WHERE a.alias = `+strings.ReplaceAll(brandAliasSQL, "manufacturer", "p.manufacturer")+`
+          AND p.brand_id IS DISTINCT FROM a.brand_id
+          AND ($1::int[] IS NULL OR p.id = ANY($1))
+    `, productIDArray(productIDs))
+	return err
+}
+
+// ApplyBrandDirectory gives every new manufacturer spelling a brand and
+// links products to their brands. It runs over the whole catalog (nil
+// productIDs) after imports and over the edited products after admin edits;
+// admins merge brands that turn out to be the same.
+func ApplyBrandDirectory(store Storage, productIDs []int) error {
+	unknown, err := store.GetUnknownManufacturers(productIDs)
+	if err != nil {
+		return err
+	}
+	for _, name := range unknown {
+		if _, err := store.CreateBrand(name); err != nil {
+			return fmt.Errorf("could not create brand %q: %w", name, err)
+		}
+	}
+	return store.ApplyBrandAliases(productIDs)
+}
+
+func (s *APIServer) getBrand(r *http.Request) (*Brand, error) {
+	var id int
+	if _, err := fmt.Sscanf(mux.Vars(r)["id"], "%d", &id); err != nil {
+		return nil, fmt.Errorf("invalid brand ID")
+	}
+	brand, err := s.store.GetBrandByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("could not get brand: %w", err)
+	}
+	if brand == nil {
+		return nil, statusErrorf(http.StatusNotFound, "brand not found")
+	}
+	return brand, nil
+}
+
+func (s *APIServer) handleGetBrand(w http.ResponseWriter, r *http.Request) error {
+	brand, err := s.getBrand(r)
+	if err != nil {
+		return err
+	}
+	return WriteJSON(w, http.StatusOK, brand)
+}
+
+func (s *APIServer) handleUpdateBrand(w http.ResponseWriter, r *http.Request) error {
+	brand, err := s.getBrand(r)
+	if err != nil {
+		return err
+	}
+
+	var req struct {
+		Name    *string `json:"name"`
+		Logo    *string `json:"logo"`
+		Website *string `json:"website"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return err
+	}
+	if req.Name != nil {
+		brand.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Logo != nil {
+		brand.Logo = strings.TrimSpace(*req.Logo)
+	}
+	if req.Website != nil {
+		brand.Website = strings.TrimSpace(*req.Website)
+	}
+	if brand.Name == "" {
+		return statusErrorf(http.StatusUnprocessableEntity, "name is required")
+	}
+	if brand.Logo != "" && !isHTTPURL(brand.Logo) {
+		return statusErrorf(http.StatusUnprocessableEntity, "logo must be an http(s) URL")
+	}
+	if brand.Website != "" && !isHTTPURL(brand.Website) {
+		return statusErrorf(http.StatusUnprocessableEntity, "website must be an http(s) URL")
+	}
+
+	if err := s.store.UpdateBrand(brand); err != nil {
+		return fmt.Errorf("failed to update brand: %w", err)
+	}
+	// The brand's own name is always an alias of it.
+	if err := s.store.SetBrandAlias(brand.ID, normalizeBrandAlias(brand.Name)); err != nil {
+		return fmt.Errorf("failed to update brand aliases: %w", err)
+	}
+	if err := s.store.ApplyBrandAliases(nil); err != nil {
+		return fmt.Errorf("failed to relink products: %w", err)
+	}
+	return WriteJSON(w, http.StatusOK, brand)
+}
+
+func (s *APIServer) handleAddBrandAlias(w http.ResponseWriter, r *http.Request) error {
+	brand, err := s.getBrand(r)
+	if err != nil {
+		return err
+	}
+
+	var req struct {
+		Alias string `json:"alias"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return err
+	}
+	alias := normalizeBrandAlias(req.Alias)
+	if alias == "" {
+		return statusErrorf(http.StatusUnprocessableEntity, "alias is required")
+	}
+
+	if err := s.store.SetBrandAlias(brand.ID, alias); err != nil {
+		return fmt.Errorf("failed to add alias: %w", err)
+	}
+	if err := s.store.ApplyBrandAliases(nil); err != nil {
+		return fmt.Errorf("failed to relink products: %w", err)
+	}
+	return s.handleGetBrand(w, r)
+}
+
+func (s *APIServer) handleMergeBrand(w http.ResponseWriter, r *http.Request) error {
+	brand, err := s.getBrand(r)
+	if err != nil {
+		return err
+	}
+
+	var req struct {
+		From int `json:"from"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return err
+	}
+	if req.From == brand.ID {
+		return statusErrorf(http.StatusUnprocessableEntity, "cannot merge a brand into itself")
+	}
+
+	if err := s.store.MergeBrands(brand.ID, req.From); err != nil {
+		if err == sql.ErrNoRows {
+			return statusErrorf(http.StatusNotFound, "brand %d not found", req.From)
+		}
+		return fmt.Errorf("failed to merge brands: %w", err)
+	}
+	return s.handleGetBrand(w, r)
+}
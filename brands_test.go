@@ -0,0 +1,43 @@
+package main
+
+import "testing"
+
+func TestSlugify(t *testing.T) {
+	tests := map[string]string{
+		"ASUS":            "asus",
+		"Western Digital": "western-digital",
+		"  G.Skill  ":     "g-skill",
+		"Ласер":           "laser",
+		"???":             "brand",
+	}
+	for name, want := range tests {
+		if got := slugify(name, "brand"); got != want {
+			t.Errorf("slugify(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestNormalizeBrandAlias(t *testing.T) {
+	if got := normalizeBrandAlias("  Western\t Digital "); got != "western digital" {
+		t.Errorf("normalizeBrandAlias = %q", got)
+	}
+}
+
+func TestDefaultBrandsAreDistinct(t *testing.T) {
+	slugs := map[string]bool{}
+	aliases := map[string]string{}
+	for _, b := range defaultBrands {
+		slug := slugify(b.name, "")
+		if slug == "" || slugs[slug] {
+			t.Errorf("brand %q has an empty or duplicate slug %q", b.name, slug)
+		}
+		slugs[slug] = true
+		for _, alias := range append([]string{b.name}, b.aliases...) {
+			alias = normalizeBrandAlias(alias)
+			if other, ok := aliases[alias]; ok && other != b.name {
+				t.Errorf("alias %q belongs to both %q and %q", alias, other, b.name)
+			}
+			aliases[alias] = b.name
+		}
+	}
+}
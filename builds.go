@@ -0,0 +1,124 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	VisibilityPrivate  = "private"
+	VisibilityUnlisted = "unlisted"
+	VisibilityPublic   = "public"
+)
+
+const publicBuildsLimit = 50
+
+// newShareSlug returns a random, URL safe identifier for sharing a build.
+func newShareSlug() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func (s *APIServer) handleSetConfigurationVisibility(w http.ResponseWriter, r *http.Request) error {
+	config, err := s.getOwnedConfiguration(r)
+	if err != nil {
+		return err
+	}
+
+	var req struct {
+		Visibility     string `json:"visibility"`
+		RegenerateSlug bool   `json:"regenerateSlug"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return err
+	}
+	switch req.Visibility {
+	case VisibilityPrivate, VisibilityUnlisted, VisibilityPublic:
+	default:
+		return statusErrorf(http.StatusUnprocessableEntity, "visibility must be one of private, unlisted or public")
+	}
+
+	slug := ""
+	if req.RegenerateSlug || (config.ShareSlug == "" && req.Visibility != VisibilityPrivate) {
+		if slug, err = newShareSlug(); err != nil {
+			return fmt.Errorf("failed to generate share link: %w", err)
+		}
+	}
+
+	slug, err = s.store.SetConfigurationVisibility(config.ID, req.Visibility, slug)
+	if err != nil {
+		return fmt.Errorf("failed to update visibility: %w", err)
+	}
+
+	return WriteJSON(w, http.StatusOK, map[string]string{"visibility": req.Visibility, "shareSlug": slug})
+}
+
+func (s *APIServer) handleGetPublicBuilds(w http.ResponseWriter, r *http.Request) error {
+	configs, err := s.store.GetPublicConfigurations(publicBuildsLimit)
+	if err != nil {
+		return fmt.Errorf("could not get builds: %w", err)
+	}
+	return WriteJSON(w, http.StatusOK, configs)
+}
+
+func (s *APIServer) handleGetSharedBuild(w http.ResponseWriter, r *http.Request) error {
+	config, err := s.getSharedConfiguration(r)
+	if err != nil {
+		return err
+	}
+	return WriteJSON(w, http.StatusOK, config)
+}
+
+func (s *APIServer) handleCloneSharedBuild(w http.ResponseWriter, r *http.Request) error {
+	userID, err := s.userIDFromRequest(r)
+	if err != nil {
+		return err
+	}
+
+	config, err := s.getSharedConfiguration(r)
+	if err != nil {
+		return err
+	}
+
+	var req struct {
+		Name string `json:"name"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return err
+		}
+	}
+	if req.Name == "" {
+		req.Name = config.Name
+	}
+	name, err := validateConfigurationName(req.Name)
+	if err != nil {
+		return err
+	}
+
+	configID, err := s.store.DuplicateConfiguration(config.ID, userID, name, "clone")
+	if err != nil {
+		return fmt.Errorf("failed to clone build: %w", err)
+	}
+
+	return WriteJSON(w, http.StatusCreated, map[string]int{"configID": configID})
+}
+
+func (s *APIServer) getSharedConfiguration(r *http.Request) (*ComputerConfiguration, error) {
+	config, err := s.store.GetConfigurationBySlug(mux.Vars(r)["slug"])
+	if err != nil {
+		return nil, fmt.Errorf("could not get build: %w", err)
+	}
+	if config == nil {
+		return nil, statusErrorf(http.StatusNotFound, "build not found")
+	}
+	return config, nil
+}
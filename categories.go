@@ -0,0 +1,472 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+// defaultCategories seeds the taxonomy. Parents are listed before their
+// children; admins can extend the tree later.
+var defaultCategories = []struct {
+	slug, parent, nameMK, nameEN string
+}{
+	{"components", "", "Компоненти", "Components"},
+	{"processors", "components", "Процесори", "Processors"},
+	{"motherboards", "components", "Матични плочи", "Motherboards"},
+	{"memory", "components", "РАМ меморија", "Memory"},
+	{"graphics-cards", "components", "Графички картички", "Graphics cards"},
+	{"storage", "components", "Складирање", "Storage"},
+	{"ssd-nvme", "storage", "NVMe SSD", "NVMe SSDs"},
+	{"ssd-sata", "storage", "SATA SSD", "SATA SSDs"},
+	{"hdd", "storage", "Хард дискови", "Hard drives"},
+	{"power-supplies", "components", "Напојувања", "Power supplies"},
+	{"cases", "components", "Кутии", "Cases"},
+	{"cooling", "components", "Ладење", "Cooling"},
+	{"cpu-coolers", "cooling", "Ладилници за процесор", "CPU coolers"},
+	{"case-fans", "cooling", "Вентилатори", "Case fans"},
+	{"peripherals", "", "Периферија", "Peripherals"},
+	{"monitors", "peripherals", "Монитори", "Monitors"},
+	{"other", "", "Останато", "Other"},
+}
+
+// componentCategories maps the component kinds from components.go onto the
+// taxonomy, for products whose store category has no mapping.
+var componentCategories = map[string]string{
+	"cpu":         "processors",
+	"motherboard": "motherboards",
+	"ram":         "memory",
+	"gpu":         "graphics-cards",
+	"storage":     "storage",
+	"psu":         "power-supplies",
+	"case":        "cases",
+	"cooler":      "cpu-coolers",
+	"fan":         "case-fans",
+	"monitor":     "monitors",
+	"other":       "other",
+}
+
+func (s *PostgressStore) CreateCategoryTables() error {
+	_, err := s.db.Exec(`
+    CREATE TABLE IF NOT EXISTS categories (
+        id SERIAL PRIMARY KEY,
+        slug TEXT NOT NULL UNIQUE,
+        parent_id INTEGER REFERENCES categories(id) ON DELETE RESTRICT,
+        name_mk TEXT NOT NULL,
+        name_en TEXT NOT NULL,
+        position INTEGER NOT NULL DEFAULT 0
+    );
+    CREATE TABLE IF NOT EXISTS category_mappings (
+        id SERIAL PRIMARY KEY,
+        store TEXT NOT NULL DEFAULT '',
+        raw TEXT NOT NULL,
+        category_id INTEGER NOT NULL REFERENCES categories(id) ON DELETE CASCADE,
+        UNIQUE (store, raw)
+    );
+    ALTER TABLE products ADD COLUMN IF NOT EXISTS category_id INTEGER REFERENCES categories(id) ON DELETE SET NULL;
+    CREATE INDEX IF NOT EXISTS products_category_id_idx ON products (category_id);
+    `)
+	if err != nil {
+		return err
+	}
+
+	for i, c := range defaultCategories {
+		_, err := s.db.Exec(`
+            INSERT INTO categories (slug, parent_id, name_mk, name_en, position)
+            VALUES ($1, (SELECT id FROM categories WHERE slug = $2), $3, $4, $5)
+            ON CONFLICT (slug) DO NOTHING
+        `, c.slug, c.parent, c.nameMK, c.nameEN, i)
+		if err != nil {
+			return fmt.Errorf("could not seed category %s: %w", c.slug, err)
+		}
+	}
+	return nil
+}
+
+var categorySlugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// normalizeRawCategory is how store categories are compared with mappings.
+func normalizeRawCategory(raw string) string {
+	return strings.ToLower(strings.TrimSpace(raw))
+}
+
+// GetCategories returns every category with the number of products assigned
+// to it directly, in display order.
+func (s *PostgressStore) GetCategories() ([]*Category, error) {
+	rows, err := s.db.Query(`
+        SELECT c.id, c.slug, c.parent_id, c.name_mk, c.name_en, COUNT(p.id)
+        FROM categories c
+        LEFT JOIN products p ON p.category_id = c.id
+        GROUP BY c.id
+        ORDER BY c.position, c.id
+    `)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var categories []*Category
+	for rows.Next() {
+		c := new(Category)
+		var parentID sql.NullInt64
+		if err := rows.Scan(&c.ID, &c.Slug, &parentID, &c.NameMK, &c.NameEN, &c.ProductCount); err != nil {
+			return nil, err
+		}
+		if parentID.Valid {
+			id := int(parentID.Int64)
+			c.ParentID = &id
+		}
+		categories = append(categories, c)
+	}
+	return categories, rows.Err()
+}
+
+// CreateCategory inserts c under the category with the given parent slug,
+// or at the top level when it is empty. It returns sql.ErrNoRows when the
+// parent does not exist.
+func (s *PostgressStore) CreateCategory(c *Category, parentSlug string) error {
+	if parentSlug != "" {
+		var parentID int
+		if err := s.db.QueryRow(`SELECT id FROM categories WHERE slug = $1`, parentSlug).Scan(&parentID); err != nil {
+			return err
+		}
+		c.ParentID = &parentID
+	}
+
+	return s.db.QueryRow(`
+        INSERT INTO categories (slug, parent_id, name_mk, name_en, position)
+        VALUES ($1, $2, $3, $4, (SELECT COALESCE(MAX(position), 0) + 1 FROM categories))
+        RETURNING id
+    `, c.Slug, c.ParentID, c.NameMK, c.NameEN).Scan(&c.ID)
+}
+
+func (s *PostgressStore) GetCategoryMappings() ([]*CategoryMapping, error) {
+	rows, err := s.db.Query(`
+        SELECT m.id, m.store, m.raw, c.slug
+        FROM category_mappings m
+        JOIN categories c ON c.id = m.category_id
+        ORDER BY m.store, m.raw
+    `)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	mappings := []*CategoryMapping{}
+	for rows.Next() {
+		m := new(CategoryMapping)
+		if err := rows.Scan(&m.ID, &m.Store, &m.Raw, &m.Category); err != nil {
+			return nil, err
+		}
+		mappings = append(mappings, m)
+	}
+	return mappings, rows.Err()
+}
+
+// SetCategoryMapping creates or replaces the mapping for m.Store and m.Raw.
+// It returns sql.ErrNoRows when m.Category is not a known slug.
+func (s *PostgressStore) SetCategoryMapping(m *CategoryMapping) error {
+	return s.db.QueryRow(`
+        INSERT INTO category_mappings (store, raw, category_id)
+        SELECT $1, $2, id FROM categories WHERE slug = $3
+        ON CONFLICT (store, raw) DO UPDATE SET category_id = EXCLUDED.category_id
+        RETURNING id
+    `, m.Store, m.Raw, m.Category).Scan(&m.ID)
+}
+
+func (s *PostgressStore) DeleteCategoryMapping(id int) error {
+	res, err := s.db.Exec(`DELETE FROM category_mappings WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetUnmappedCategories returns the raw store categories no mapping covers,
+// most common first.
+func (s *PostgressStore) GetUnmappedCategories() ([]*UnmappedCategory, error) {
+	rows, err := s.db.Query(`
+        SELECT COALESCE(p.store, ''), COALESCE(p.category, ''), COUNT(*)
+        FROM products p
+        WHERE NOT EXISTS (
+            SELECT 1 FROM category_mappings m
+            WHERE m.raw = LOWER(TRIM(p.category)) AND m.store IN ('', p.store)
+        )
+        GROUP BY p.store, p.category
+        ORDER BY COUNT(*) DESC, p.store, p.category
+    `)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	unmapped := []*UnmappedCategory{}
+	for rows.Next() {
+		u := new(UnmappedCategory)
+		if err := rows.Scan(&u.Store, &u.Raw, &u.ProductCount); err != nil {
+			return nil, err
+		}
+		unmapped = append(unmapped, u)
+	}
+	return unmapped, rows.Err()
+}
+
+// ApplyCategoryMappings assigns canonical categories to products from the
+// mapping table, preferring store-specific mappings, and returns the
+// products no mapping covers. productIDs limits it to those products; nil
+// means the whole catalog.
+func (s *PostgressStore) ApplyCategoryMappings(productIDs []int) ([]*Product, error) {
+	ids := productIDArray(productIDs)
+	_, err := s.db.Exec(`
+        UPDATE products p SET category_id = m.category_id
+        FROM (
+            SELECT DISTINCT ON (q.id) q.id AS product_id, cm.category_id
+            FROM products q
+            JOIN category_mappings cm ON cm.raw = LOWER(TRIM(q.category)) AND cm.store IN ('', q.store)
+            WHERE $1::int[] IS NULL OR q.id = ANY($1)
+            ORDER BY q.id, cm.store DESC
+        ) m
+        WHERE p.id = m.product_id AND p.category_id IS DISTINCT FROM m.category_id
+    `, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(`
+        SELECT p.id, COALESCE(p.title, ''), COALESCE(p.category, '')
+        FROM products p
+        WHERE NOT EXISTS (
+            SELECT 1 FROM category_mappings m
+            WHERE m.raw = LOWER(TRIM(p.category)) AND m.store IN ('', p.store)
+        )
+        AND ($1::int[] IS NULL OR p.id = ANY($1))
+    `, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var unmapped []*Product
+	for rows.Next() {
+		p := new(Product)
+		if err := rows.Scan(&p.ID, &p.Title, &p.Category); err != nil {
+			return nil, err
+		}
+		unmapped = append(unmapped, p)
+	}
+	return unmapped, rows.Err()
+}
+
+// SetProductCategories assigns the categories with the given slugs, keyed
+// by product ID.
+func (s *PostgressStore) SetProductCategories(slugs map[int]string) error {
+	if len(slugs) == 0 {
+		return nil
+	}
+	ids := make([]int64, 0, len(slugs))
+	values := make([]string, 0, len(slugs))
+	for id, slug := range slugs {
+		ids = append(ids, int64(id))
+		values = append(values, slug)
+	}
+
+	_, err := s.db.Exec(`
+        UPDATE products p SET category_id = c.id
+        FROM unnest($1::int[], $2::text[]) AS v(product_id, slug)
+        JOIN categories c ON c.slug = v.slug
+        WHERE p.id = v.product_id AND p.category_id IS DISTINCT FROM c.id
+    `, pq.Array(ids), pq.Array(values))
+	return err
+}
+
+// fallbackCategory picks a category for a product from its title and store
+// category when no mapping exists.
+func fallbackCategory(p *Product) string {
+	kind := componentKind(p)
+	if kind != "storage" {
+		return componentCategories[kind]
+	}
+
+	text := strings.ToLower(p.Title + " " + p.Category)
+	switch {
+	case strings.Contains(text, "nvme") || strings.Contains(text, "m.2"):
+		return "ssd-nvme"
+	case strings.Contains(text, "ssd"):
+		return "ssd-sata"
+	case strings.Contains(text, "hdd") || strings.Contains(text, "хард"):
+		return "hdd"
+	}
+	return "storage"
+}
+
+// ApplyCategoryTaxonomy assigns products a canonical category, from the
+// mapping table where one matches and by classification otherwise. It runs
+// over the whole catalog (nil productIDs) after imports and whenever
+// mappings change, and over the edited products after admin edits.
+func ApplyCategoryTaxonomy(store Storage, productIDs []int) error {
+	unmapped, err := store.ApplyCategoryMappings(productIDs)
+	if err != nil {
+		return err
+	}
+
+	slugs := make(map[int]string, len(unmapped))
+	for _, p := range unmapped {
+		slugs[p.ID] = fallbackCategory(p)
+	}
+	return store.SetProductCategories(slugs)
+}
+
+// categoryTree links categories to their children, rolls product counts up
+// to the ancestors and returns the top-level categories.
+func categoryTree(categories []*Category, lang string) []*Category {
+	byID := make(map[int]*Category, len(categories))
+	for _, c := range categories {
+		c.Name = c.NameMK
+		if lang == "en" {
+			c.Name = c.NameEN
+		}
+		c.Children = []*Category{}
+		byID[c.ID] = c
+	}
+
+	roots := []*Category{}
+	for _, c := range categories {
+		parent := (*Category)(nil)
+		if c.ParentID != nil {
+			parent = byID[*c.ParentID]
+		}
+		if parent == nil {
+			roots = append(roots, c)
+			continue
+		}
+		parent.Children = append(parent.Children, c)
+	}
+
+	var total func(c *Category) int
+	total = func(c *Category) int {
+		for _, child := range c.Children {
+			c.ProductCount += total(child)
+		}
+		return c.ProductCount
+	}
+	for _, c := range roots {
+		total(c)
+	}
+	return roots
+}
+
+func (s *APIServer) handleGetCategories(w http.ResponseWriter, r *http.Request) error {
+	lang := r.URL.Query().Get("lang")
+	if lang != "" && lang != "mk" && lang != "en" {
+		return statusErrorf(http.StatusUnprocessableEntity, "lang must be mk or en")
+	}
+
+	categories, err := s.store.GetCategories()
+	if err != nil {
+		return fmt.Errorf("failed to fetch categories: %w", err)
+	}
+	return WriteJSON(w, http.StatusOK, categoryTree(categories, lang))
+}
+
+func (s *APIServer) handleCreateCategory(w http.ResponseWriter, r *http.Request) error {
+	var req struct {
+		Slug   string `json:"slug"`
+		Parent string `json:"parent"`
+		NameMK string `json:"nameMk"`
+		NameEN string `json:"nameEn"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return err
+	}
+
+	c := &Category{
+		Slug:   strings.ToLower(strings.TrimSpace(req.Slug)),
+		NameMK: strings.TrimSpace(req.NameMK),
+		NameEN: strings.TrimSpace(req.NameEN),
+	}
+	if !categorySlugPattern.MatchString(c.Slug) {
+		return statusErrorf(http.StatusUnprocessableEntity, "slug must consist of lowercase letters, digits and dashes")
+	}
+	if c.NameMK == "" || c.NameEN == "" {
+		return statusErrorf(http.StatusUnprocessableEntity, "both nameMk and nameEn are required")
+	}
+
+	if err := s.store.CreateCategory(c, req.Parent); err != nil {
+		if err == sql.ErrNoRows {
+			return statusErrorf(http.StatusUnprocessableEntity, "unknown parent category %q", req.Parent)
+		}
+		if isUniqueViolation(err) {
+			return statusErrorf(http.StatusConflict, "category %q already exists", c.Slug)
+		}
+		return fmt.Errorf("failed to create category: %w", err)
+	}
+	return WriteJSON(w, http.StatusCreated, c)
+}
+
+func (s *APIServer) handleGetCategoryMappings(w http.ResponseWriter, r *http.Request) error {
+	mappings, err := s.store.GetCategoryMappings()
+	if err != nil {
+		return fmt.Errorf("failed to fetch category mappings: %w", err)
+	}
+	return WriteJSON(w, http.StatusOK, mappings)
+}
+
+func (s *APIServer) handleGetUnmappedCategories(w http.ResponseWriter, r *http.Request) error {
+	unmapped, err := s.store.GetUnmappedCategories()
+	if err != nil {
+		return fmt.Errorf("failed to fetch unmapped categories: %w", err)
+	}
+	return WriteJSON(w, http.StatusOK, unmapped)
+}
+
+func (s *APIServer) handleSetCategoryMapping(w http.ResponseWriter, r *http.Request) error {
+	m := new(CategoryMapping)
+	if err := json.NewDecoder(r.Body).Decode(m); err != nil {
+		return err
+	}
+	m.Store = strings.TrimSpace(m.Store)
+	m.Raw = normalizeRawCategory(m.Raw)
+	if m.Raw == "" {
+		return statusErrorf(http.StatusUnprocessableEntity, "raw category is required")
+	}
+
+	if err := s.store.SetCategoryMapping(m); err != nil {
+		if err == sql.ErrNoRows {
+			return statusErrorf(http.StatusUnprocessableEntity, "unknown category %q", m.Category)
+		}
+		return fmt.Errorf("failed to save category mapping: %w", err)
+	}
+	if err := ApplyCategoryTaxonomy(s.store, nil); err != nil {
+		return fmt.Errorf("mapping saved but products could not be recategorized: %w", err)
+	}
+	return WriteJSON(w, http.StatusOK, m)
+}
+
+func (s *APIServer) handleDeleteCategoryMapping(w http.ResponseWriter, r *http.Request) error {
+	var id int
+	if _, err := fmt.Sscanf(mux.Vars(r)["id"], "%d", &id); err != nil {
+		return fmt.Errorf("invalid mapping ID")
+	}
+
+	if err := s.store.DeleteCategoryMapping(id); err != nil {
+		if err == sql.ErrNoRows {
+			return statusErrorf(http.StatusNotFound, "category mapping not found")
+		}
+		return fmt.Errorf("failed to delete category mapping: %w", err)
+	}
+	if err := ApplyCategoryTaxonomy(s.store, nil); err != nil {
+		return fmt.Errorf("mapping deleted but products could not be recategorized: %w", err)
+	}
+	return WriteJSON(w, http.StatusOK, map[string]string{"message": "category mapping deleted"})
+}
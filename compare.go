@@ -0,0 +1,192 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	minCompareProducts  = 2
+	maxCompareProducts  = 4
+	maxDescriptionSpecs = 30
+)
+
+var capacityPattern = regexp.MustCompile(`(?i)\b(\d+(?:[.,]\d+)?)\s*(GB|TB)\b`)
+
+// ComparisonRow is one attribute across the compared products. Best holds
+// the index of the preferable value where one can be told, e.g. the lowest
+// price.
+type ComparisonRow struct {
+	Attribute string   `json:"attribute"`
+	Values    []string `json:"values"`
+	Differs   bool     `json:"differs"`
+	Best      *int     `json:"best,omitempty"`
+}
+
+type ProductComparison struct {
+	Category string           `json:"category"`
+	Products []*Product       `json:"products"`
+	Rows     []*ComparisonRow `json:"rows"`
+}
+
+// productSpecs extracts structured attributes of a product from its title
+// and from "key: value" pairs in its description.
+func productSpecs(p *Product) map[string]string {
+	specs := map[string]string{}
+
+	for _, line := range strings.FieldsFunc(p.Description, func(r rune) bool {
+		return r == '\n' || r == ';' || r == '|'
+	}) {
+		key, value, ok := strings.Cut(line, ":")
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+		if !ok || key == "" || value == "" || len([]rune(key)) > 40 {
+			continue
+		}
+		if len(specs) == maxDescriptionSpecs {
+			break
+		}
+		specs[key] = value
+	}
+
+	kind := componentKind(p)
+	switch kind {
+	case "cpu":
+		if socket := cpuSocket(p); socket != "" {
+			specs["socket"] = socket
+		}
+	case "motherboard":
+		if socket := boardSocket(p); socket != "" {
+			specs["socket"] = socket
+		}
+	case "psu":
+		if watts := psuWattage(p); watts > 0 {
+			specs["wattage"] = fmt.Sprintf("%dW", watts)
+		}
+	}
+	if kind == "ram" || kind == "motherboard" {
+		if t := memoryType(p); t != "" {
+			specs["memory type"] = t
+		}
+	}
+	if kind == "ram" || kind == "storage" || kind == "gpu" {
+		if m := capacityPattern.FindStringSubmatch(p.Title); m != nil {
+			specs["capacity"] = m[1] + " " + strings.ToUpper(m[2])
+		}
+	}
+	if watts, source := componentPower(kind, p); source == "listing" || source == "model" {
+		specs["power"] = fmt.Sprintf("%dW", watts)
+	}
+	return specs
+}
+
+// compareCategory returns the category products are grouped by for
+// comparison: the component kind when known, the store category otherwise.
+func compareCategory(p *Product) string {
+	if kind := componentKind(p); kind != "other" {
+		return kind
+	}
+	return strings.ToLower(strings.TrimSpace(p.Category))
+}
+
+func compareProducts(products []*Product) *ProductComparison {
+	c := &ProductComparison{Category: compareCategory(products[0]), Products: products}
+
+	addRow := func(attribute string, value func(i int) string, better func(a, b *Product) bool) {
+		row := &ComparisonRow{Attribute: attribute, Values: make([]string, len(products))}
+		for i := range products {
+			row.Values[i] = value(i)
+			if row.Values[i] != row.Values[0] {
+				row.Differs = true
+			}
+		}
+		if row.Differs && better != nil {
+			best := 0
+			for i, p := range products {
+				if better(p, products[best]) {
+					best = i
+				}
+			}
+			row.Best = &best
+		}
+		c.Rows = append(c.Rows, row)
+	}
+
+	addRow("price", func(i int) string { return strconv.FormatInt(products[i].Price, 10) },
+		// Listings without a price never win, even when they come first.
+		func(a, b *Product) bool { return a.Price > 0 && (b.Price <= 0 || a.Price < b.Price) })
+	addRow("warranty", func(i int) string { return strconv.FormatInt(products[i].Warranty, 10) },
+		func(a, b *Product) bool { return a.Warranty > b.Warranty })
+	addRow("store", func(i int) string { return products[i].Store }, nil)
+	addRow("manufacturer", func(i int) string { return products[i].Manufacturer }, nil)
+	addRow("code", func(i int) string { return products[i].Code }, nil)
+
+	specs := make([]map[string]string, len(products))
+	seen := map[string]int{}
+	for i, p := range products {
+		specs[i] = productSpecs(p)
+		for key := range specs[i] {
+			seen[key]++
+		}
+	}
+	keys := make([]string, 0, len(seen))
+	for key := range seen {
+		keys = append(keys, key)
+	}
+	// Attributes every product has come first; they are the comparable ones.
+	sort.Slice(keys, func(i, j int) bool {
+		if seen[keys[i]] != seen[keys[j]] {
+			return seen[keys[i]] > seen[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	for _, key := range keys {
+		addRow(key, func(i int) string { return specs[i][key] }, nil)
+	}
+
+	return c
+}
+
+func (s *APIServer) handleCompareProducts(w http.ResponseWriter, r *http.Request) error {
+	ids := queryList(r.URL.Query(), "ids")
+	if len(ids) < minCompareProducts || len(ids) > maxCompareProducts {
+		return statusErrorf(http.StatusUnprocessableEntity, "compare between %d and %d products", minCompareProducts, maxCompareProducts)
+	}
+
+	seen := map[int]bool{}
+	products := make([]*Product, 0, len(ids))
+	for _, idStr := range ids {
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			return statusErrorf(http.StatusUnprocessableEntity, "invalid product ID %q", idStr)
+		}
+		if seen[id] {
+			return statusErrorf(http.StatusUnprocessableEntity, "product %d is listed more than once", id)
+		}
+		seen[id] = true
+
+		product, err := s.store.GetProductByID(id)
+		if err != nil {
+			return fmt.Errorf("could not fetch product: %w", err)
+		}
+		if product == nil {
+			return statusErrorf(http.StatusNotFound, "product %d not found", id)
+		}
+		products = append(products, product)
+	}
+
+	category := compareCategory(products[0])
+	for _, p := range products[1:] {
+		if compareCategory(p) != category {
+			return statusErrorf(http.StatusUnprocessableEntity,
+				"cannot compare products from different categories: %q (%d) and %q (%d)",
+				products[0].Category, products[0].ID, p.Category, p.ID)
+		}
+	}
+
+	return WriteJSON(w, http.StatusOK, compareProducts(products))
+}
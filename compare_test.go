@@ -0,0 +1,25 @@
+package main
+
+import "testing"
+
+func TestCompareProductsBestPrice(t *testing.T) {
+	tests := []struct {
+		prices []int64
+		best   int
+	}{
+		{[]int64{0, 500, 400}, 2},
+		{[]int64{300, 0, 400}, 0},
+		{[]int64{500, 400, 0}, 1},
+	}
+	for _, tt := range tests {
+		products := make([]*Product, len(tt.prices))
+		for i, price := range tt.prices {
+			products[i] = &Product{ID: i + 1, Title: "Ryzen 5 7600", Price: price}
+		}
+		c := compareProducts(products)
+		row := c.Rows[0]
+		if row.Attribute != "price" || row.Best == nil || *row.Best != tt.best {
+			t.Errorf("prices %v: best = %v, want %d", tt.prices, row.Best, tt.best)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// componentKeywords maps a component kind to words that identify it in store
+// categories and product titles, in English and Macedonian. Kinds match the
+// configuration slot roles.
+var componentKeywords = []struct {
+	kind     string
+	keywords []string
+}{
+	{"cooler", []string{"ладилник", "ладење", "cooler", "cooling", "aio"}},
+	{"gpu", []string{"графичк", "видео картич", "graphics card", "video card", "gpu", "geforce", "radeon rx", "rtx", "gtx"}},
+	{"cpu", []string{"процесор", "processor", "cpu"}},
+	{"motherboard", []string{"матичн", "motherboard", "mainboard"}},
+	{"psu", []string{"напојувањ", "power supply", "psu"}},
+	{"fan", []string{"вентилатор", "fan"}},
+	{"ram", []string{"рам", "ram", "memory", "меморија", "dimm", "ddr3", "ddr4", "ddr5"}},
+	{"storage", []string{"ssd", "hdd", "nvme", "хард диск", "storage", "диск"}},
+	{"case", []string{"кутија", "куќиште", "case", "chassis", "tower"}},
+	{"monitor", []string{"монитор", "monitor"}},
+}
+
+func componentKeywordsFor(kind string) []string {
+	for _, c := range componentKeywords {
+		if c.kind == kind {
+			return c.keywords
+		}
+	}
+	return nil
+}
+
+// componentKind classifies a product by its store category, falling back to
+// its title. It returns "other" for products it does not recognise. Keywords
+// are checked in order, so "CPU cooler" is a cooler rather than a CPU.
+func componentKind(p *Product) string {
+	if kind := matchComponentKind(p.Category); kind != "" {
+		return kind
+	}
+	if kind := matchComponentKind(p.Title); kind != "" {
+		return kind
+	}
+	return "other"
+}
+
+func matchComponentKind(s string) string {
+	s = strings.ToLower(s)
+	for _, c := range componentKeywords {
+		for _, k := range c.keywords {
+			if containsWordPrefix(s, k) {
+				return c.kind
+			}
+		}
+	}
+	return ""
+}
+
+// containsWordPrefix reports whether s contains a word starting with k, so
+// that "ram" matches "RAM" and "ramm" but not "frame".
+func containsWordPrefix(s, k string) bool {
+	for i := 0; ; {
+		j := strings.Index(s[i:], k)
+		if j < 0 {
+			return false
+		}
+		start := i + j
+		prev, _ := utf8.DecodeLastRuneInString(s[:start])
+		if start == 0 || !unicode.IsLetter(prev) {
+			return true
+		}
+		i = start + len(k)
+	}
+}
+
+// itemKind returns the kind of a configuration item, preferring the role of
+// the slot it was placed in.
+func itemKind(item *ConfigurationItem) string {
+	if role, _, _ := strings.Cut(item.Slot, "-"); role != "" {
+		return role
+	}
+	return componentKind(item.Product)
+}
+
+var (
+	ratedPowerPattern = regexp.MustCompile(`(?i)(?:tdp|tbp|tgp|board power|power consumption|потрошувачка)\D{0,20}(\d{2,3})\s*w\b`)
+	wattsPattern      = regexp.MustCompile(`(?i)\b(\d{2,4})\s*w\b`)
+)
+
+// ratedPower returns the power rating stated in the product title or
+// description, such as "TDP 65W".
+func ratedPower(p *Product) (int, bool) {
+	for _, s := range []string{p.Title, p.Description} {
+		if m := ratedPowerPattern.FindStringSubmatch(s); m != nil {
+			n, _ := strconv.Atoi(m[1])
+			return n, true
+		}
+	}
+	return 0, false
+}
+
+// psuWattage returns the output wattage of a power supply from its title.
+func psuWattage(p *Product) int {
+	best := 0
+	for _, m := range wattsPattern.FindAllStringSubmatch(p.Title, -1) {
+		if n, _ := strconv.Atoi(m[1]); n >= 200 && n > best {
+			best = n
+		}
+	}
+	return best
+}
+
+var (
+	socketPattern     = regexp.MustCompile(`(?i)\b(AM4|AM5|LGA\s*-?\s*(?:1200|1700|1851))\b`)
+	memoryTypePattern = regexp.MustCompile(`(?i)\b(DDR[345])\b`)
+	ryzenPattern      = regexp.MustCompile(`(?i)\bryzen\s+(?:[3579]|threadripper)\s+(?:pro\s+)?(\d)\d{3}`)
+	intelCorePattern  = regexp.MustCompile(`(?i)\bi[3579]-?\s*(\d{4,5})([a-z]*)\b`)
+	coreUltraPattern  = regexp.MustCompile(`(?i)\bultra\s+[579]\s+2\d{2}([a-z]*)\b`)
+	ryzenAPUPattern   = regexp.MustCompile(`(?i)\bryzen\s+[3579]\s+\d{4}G`)
+	ryzenNoGPUPattern = regexp.MustCompile(`(?i)\bryzen\s+[3579]\s+\d{4}F`)
+)
+
+// chipsetSockets maps motherboard chipsets to the CPU socket they serve.
+var chipsetSockets = map[string]string{
+	"A520": "AM4", "B450": "AM4", "B550": "AM4", "X470": "AM4", "X570": "AM4",
+	"A620": "AM5", "B650": "AM5", "B850": "AM5", "X670": "AM5", "X870": "AM5",
+	"H410": "LGA1200", "B460": "LGA1200", "Z490": "LGA1200", "H510": "LGA1200", "B560": "LGA1200", "Z590": "LGA1200",
+	"H610": "LGA1700", "B660": "LGA1700", "H670": "LGA1700", "Z690": "LGA1700", "B760": "LGA1700", "Z790": "LGA1700",
+	"B860": "LGA1851", "Z890": "LGA1851",
+}
+
+var chipsetPattern = regexp.MustCompile(`(?i)\b([ABHXZ]\d{3})[EM]?\b`)
+
+func normalizeSocket(s string) string {
+	s = strings.ToUpper(s)
+	s = strings.NewReplacer(" ", "", "-", "").Replace(s)
+	return s
+}
+
+// cpuSocket returns the socket of a processor, either stated in the listing
+// or inferred from the model number. It returns "" when unknown.
+func cpuSocket(p *Product) string {
+	text := p.Title + " " + p.Description
+	if m := socketPattern.FindStringSubmatch(text); m != nil {
+		return normalizeSocket(m[1])
+	}
+	if m := ryzenPattern.FindStringSubmatch(p.Title); m != nil {
+		if m[1] >= "7" {
+			return "AM5"
+		}
+		return "AM4"
+	}
+	if m := intelCorePattern.FindStringSubmatch(p.Title); m != nil {
+		switch {
+		case strings.HasPrefix(m[1], "1") && len(m[1]) == 5 && m[1] >= "12000":
+			return "LGA1700"
+		case strings.HasPrefix(m[1], "1") && len(m[1]) == 5:
+			return "LGA1200"
+		}
+	}
+	if coreUltraPattern.MatchString(p.Title) {
+		return "LGA1851"
+	}
+	return ""
+}
+
+// boardSocket returns the CPU socket of a motherboard from its listing or
+// chipset. It returns "" when unknown.
+func boardSocket(p *Product) string {
+	text := p.Title + " " + p.Description
+	if m := socketPattern.FindStringSubmatch(text); m != nil {
+		return normalizeSocket(m[1])
+	}
+	for _, m := range chipsetPattern.FindAllStringSubmatch(p.Title, -1) {
+		if socket, ok := chipsetSockets[strings.ToUpper(m[1])]; ok {
+			return socket
+		}
+	}
+	return ""
+}
+
+// memoryType returns "DDR4" or "DDR5" for memory modules and motherboards.
+// Boards for AM4 and AM5 imply the type when the listing omits it.
+func memoryType(p *Product) string {
+	if m := memoryTypePattern.FindStringSubmatch(p.Title); m != nil {
+		return strings.ToUpper(m[1])
+	}
+	if componentKind(p) == "motherboard" {
+		switch boardSocket(p) {
+		case "AM4":
+			return "DDR4"
+		case "AM5", "LGA1851":
+			return "DDR5"
+		}
+	}
+	return ""
+}
+
+// cpuBrand returns "amd" or "intel" for processors.
+func cpuBrand(p *Product) string {
+	text := strings.ToLower(p.Manufacturer + " " + p.Title)
+	switch {
+	case strings.Contains(text, "amd") || strings.Contains(text, "ryzen"):
+		return "amd"
+	case strings.Contains(text, "intel") || strings.Contains(text, "core"):
+		return "intel"
+	}
+	return ""
+}
+
+// hasIntegratedGraphics reports whether a processor can drive a display
+// without a graphics card.
+func hasIntegratedGraphics(p *Product) bool {
+	if ryzenAPUPattern.MatchString(p.Title) {
+		return true
+	}
+	if cpuSocket(p) == "AM5" {
+		return !ryzenNoGPUPattern.MatchString(p.Title)
+	}
+	if m := intelCorePattern.FindStringSubmatch(p.Title); m != nil {
+		return !strings.Contains(strings.ToUpper(m[2]), "F")
+	}
+	if m := coreUltraPattern.FindStringSubmatch(p.Title); m != nil {
+		return !strings.Contains(strings.ToUpper(m[1]), "F")
+	}
+	return false
+}
@@ -0,0 +1,128 @@
+package main
+
+import "testing"
+
+func TestComponentKind(t *testing.T) {
+	tests := []struct {
+		category, title string
+		want            string
+	}{
+		{"Процесори", "AMD Ryzen 5 7600", "cpu"},
+		{"", "Noctua NH-U12S CPU Cooler", "cooler"},
+		{"Графички картички", "Gigabyte RTX 4060", "gpu"},
+		{"", "Kingston Fury Beast 16GB DDR5", "ram"},
+		{"", "Fractal Design Frame", "other"},
+		{"Напојувања", "Corsair RM750e", "psu"},
+		{"", "Samsung 990 Pro 1TB NVMe SSD", "storage"},
+		{"", "Logitech G Pro mouse", "other"},
+	}
+	for _, tt := range tests {
+		if got := componentKind(&Product{Category: tt.category, Title: tt.title}); got != tt.want {
+			t.Errorf("componentKind(%q, %q) = %q, want %q", tt.category, tt.title, got, tt.want)
+		}
+	}
+}
+
+func TestCPUSocket(t *testing.T) {
+	tests := map[string]string{
+		"AMD Ryzen 5 5600X":              "AM4",
+		"AMD Ryzen 7 7800X3D":            "AM5",
+		"AMD Ryzen 5 8600G":              "AM5",
+		"Intel Core i5-12400F":           "LGA1700",
+		"Intel Core i7-14700K":           "LGA1700",
+		"Intel Core i5-10400":            "LGA1200",
+		"Intel Core Ultra 7 265K":        "LGA1851",
+		"Intel Pentium Gold LGA 1700 G7": "LGA1700",
+		"Intel Celeron G5905":            "",
+	}
+	for title, want := range tests {
+		if got := cpuSocket(&Product{Title: title}); got != want {
+			t.Errorf("cpuSocket(%q) = %q, want %q", title, got, want)
+		}
+	}
+}
+
+func TestBoardSocket(t *testing.T) {
+	tests := map[string]string{
+		"MSI B650 Tomahawk WiFi":  "AM5",
+		"ASUS PRIME B550M-A":      "AM4",
+		"Gigabyte Z790 AORUS":     "LGA1700",
+		"ASRock H610M-HDV":        "LGA1700",
+		"Biostar socket AM4 A320": "AM4",
+		"Generic motherboard":     "",
+	}
+	for title, want := range tests {
+		if got := boardSocket(&Product{Title: title}); got != want {
+			t.Errorf("boardSocket(%q) = %q, want %q", title, got, want)
+		}
+	}
+}
+
+func TestPSUWattage(t *testing.T) {
+	tests := map[string]int{
+		"Corsair RM750e 750W 80+ Gold":  750,
+		"Be Quiet! Pure Power 12 1000W": 1000,
+		"Seasonic Focus GX-650 650 W":   650,
+		"Fan 120mm 5W":                  0,
+		"Power supply":                  0,
+	}
+	for title, want := range tests {
+		if got := psuWattage(&Product{Title: title}); got != want {
+			t.Errorf("psuWattage(%q) = %d, want %d", title, got, want)
+		}
+	}
+}
+
+func TestMemoryType(t *testing.T) {
+	tests := []struct {
+		category, title string
+		want            string
+	}{
+		{"RAM", "Kingston Fury 16GB DDR5 6000", "DDR5"},
+		{"RAM", "Corsair Vengeance ddr4 3200", "DDR4"},
+		{"Матични плочи", "MSI B550 Gaming Plus", "DDR4"},
+		{"Матични плочи", "MSI B650 Gaming Plus", "DDR5"},
+		{"RAM", "Memory module", ""},
+	}
+	for _, tt := range tests {
+		if got := memoryType(&Product{Category: tt.category, Title: tt.title}); got != tt.want {
+			t.Errorf("memoryType(%q) = %q, want %q", tt.title, got, tt.want)
+		}
+	}
+}
+
+func TestRatedPower(t *testing.T) {
+	tests := []struct {
+		title, description string
+		want               int
+		ok                 bool
+	}{
+		{"AMD Ryzen 5 7600 TDP 65W", "", 65, true},
+		{"RTX 4070", "Total board power: 200 W", 200, true},
+		{"Intel Core i5-12400", "", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := ratedPower(&Product{Title: tt.title, Description: tt.description})
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("ratedPower(%q) = %d, %v; want %d, %v", tt.title, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestContainsWordPrefix(t *testing.T) {
+	tests := []struct {
+		s, k string
+		want bool
+	}{
+		{"kingston ram 16gb", "ram", true},
+		{"ramm", "ram", true},
+		{"fractal frame", "ram", false},
+		{"фан фрејм рам", "рам", true},
+		{"програм", "рам", false},
+	}
+	for _, tt := range tests {
+		if got := containsWordPrefix(tt.s, tt.k); got != tt.want {
+			t.Errorf("containsWordPrefix(%q, %q) = %v, want %v", tt.s, tt.k, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	maxConfigurationNameLength = 100
+	maxItemQuantity            = 16
+	maxSlotIndex               = 16
+)
+
+// slotRoles are the component roles an item can fill. A slot is a role,
+// optionally numbered for repeated components, e.g. "storage-2".
+var slotRoles = map[string]bool{
+	"cpu":         true,
+	"cooler":      true,
+	"motherboard": true,
+	"ram":         true,
+	"gpu":         true,
+	"storage":     true,
+	"psu":         true,
+	"case":        true,
+	"fan":         true,
+	"monitor":     true,
+	"peripheral":  true,
+	"other":       true,
+}
+
+func validateSlot(slot string) (string, error) {
+	slot = strings.ToLower(strings.TrimSpace(slot))
+	if slot == "" {
+		return "", nil
+	}
+
+	role, index, numbered := strings.Cut(slot, "-")
+	if !slotRoles[role] {
+		return "", statusErrorf(http.StatusUnprocessableEntity, "unknown slot %q", slot)
+	}
+	if numbered {
+		n, err := strconv.Atoi(index)
+		if err != nil || n < 1 || n > maxSlotIndex || strconv.Itoa(n) != index {
+			return "", statusErrorf(http.StatusUnprocessableEntity, "invalid slot number in %q", slot)
+		}
+	}
+	return slot, nil
+}
+
+func validateQuantity(quantity int) error {
+	if quantity < 1 || quantity > maxItemQuantity {
+		return statusErrorf(http.StatusUnprocessableEntity, "quantity must be between 1 and %d", maxItemQuantity)
+	}
+	return nil
+}
+
+func validateConfigurationName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", fmt.Errorf("configuration name is required")
+	}
+	if len([]rune(name)) > maxConfigurationNameLength {
+		return "", fmt.Errorf("configuration name must be at most %d characters", maxConfigurationNameLength)
+	}
+	return name, nil
+}
+
+// copyName returns the default name of a duplicated configuration, shortening
+// name so that the result stays within the length limit.
+func copyName(name string) string {
+	const suffix = " (copy)"
+	runes := []rune(strings.TrimSpace(name))
+	if limit := maxConfigurationNameLength - len(suffix); len(runes) > limit {
+		runes = []rune(strings.TrimSpace(string(runes[:limit])))
+	}
+	return string(runes) + suffix
+}
+
+// priceConfiguration fills in the totals of c from its items. equivalents
+// maps a product ID to the same part listed by other stores and is used to
+// compute the cheapest possible total.
+func priceConfiguration(c *ComputerConfiguration, equivalents map[int][]*Product) {
+	c.TotalPrice = 0
+	c.CheapestTotalPrice = 0
+	c.CategoryTotals = map[string]int64{}
+	c.StoreTotals = map[string]int64{}
+	c.Warnings = []*ConfigurationWarning{}
+
+	for _, item := range c.Items {
+		p := item.Product
+		quantity := int64(item.Quantity)
+		c.TotalPrice += p.Price * quantity
+		c.CategoryTotals[p.Category] += p.Price * quantity
+		c.StoreTotals[p.Store] += p.Price * quantity
+		c.CheapestTotalPrice += cheapestListing(p, equivalents[p.ID]).Price * quantity
+		if message, ok := availabilityWarnings[p.Availability]; ok {
+			c.Warnings = append(c.Warnings, &ConfigurationWarning{
+				ItemID: item.ID, ProductID: p.ID, Availability: p.Availability, Message: message,
+			})
+		}
+	}
+}
+
+// cheapestListing returns the lowest priced product among p and its
+// equivalents. Equivalents without a price or out of stock are ignored; an
+// unpriced p is replaced by any priced equivalent.
+func cheapestListing(p *Product, equivalents []*Product) *Product {
+	cheapest := p
+	for _, e := range equivalents {
+		if e.Price > 0 && e.Availability != AvailabilityOutOfStock && (cheapest.Price <= 0 || e.Price < cheapest.Price) {
+			cheapest = e
+		}
+	}
+	return cheapest
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCopyName(t *testing.T) {
+	if got := copyName("Gaming PC"); got != "Gaming PC (copy)" {
+		t.Errorf("got %q", got)
+	}
+
+	long := strings.Repeat("ш", maxConfigurationNameLength)
+	got := copyName(long)
+	if _, err := validateConfigurationName(got); err != nil {
+		t.Errorf("copy of a long name is invalid: %v", err)
+	}
+	if !strings.HasSuffix(got, " (copy)") {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestValidateSlot(t *testing.T) {
+	valid := map[string]string{
+		"":           "",
+		"cpu":        "cpu",
+		" GPU ":      "gpu",
+		"storage-2":  "storage-2",
+		"storage-16": "storage-16",
+	}
+	for in, want := range valid {
+		got, err := validateSlot(in)
+		if err != nil || got != want {
+			t.Errorf("validateSlot(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+
+	for _, in := range []string{"sound", "storage-0", "storage-17", "storage-02", "storage-x", "cpu-"} {
+		if _, err := validateSlot(in); err == nil {
+			t.Errorf("validateSlot(%q) succeeded", in)
+		}
+	}
+}
+
+func TestValidateQuantity(t *testing.T) {
+	for _, q := range []int{1, maxItemQuantity} {
+		if err := validateQuantity(q); err != nil {
+			t.Errorf("validateQuantity(%d) = %v", q, err)
+		}
+	}
+	for _, q := range []int{0, -1, maxItemQuantity + 1} {
+		if err := validateQuantity(q); err == nil {
+			t.Errorf("validateQuantity(%d) succeeded", q)
+		}
+	}
+}
+
+func TestPriceConfiguration(t *testing.T) {
+	cpu := &Product{ID: 1, Category: "CPU", Store: "A", Price: 300}
+	ram := &Product{ID: 2, Category: "RAM", Store: "B", Price: 50, Availability: AvailabilityOnOrder}
+	fan := &Product{ID: 3, Category: "Cooling", Store: "A", Price: 0}
+	c := &ComputerConfiguration{Items: []*ConfigurationItem{
+		{ID: 10, ProductID: 1, Quantity: 1, Product: cpu},
+		{ID: 11, ProductID: 2, Quantity: 2, Product: ram},
+		{ID: 12, ProductID: 3, Quantity: 3, Product: fan},
+	}}
+	equivalents := map[int][]*Product{
+		1: {
+			{ID: 4, Store: "B", Price: 280},
+			{ID: 5, Store: "C", Price: 0},
+			{ID: 6, Store: "C", Price: 200, Availability: AvailabilityOutOfStock},
+		},
+		2: {{ID: 7, Store: "A", Price: 60}},
+		3: {{ID: 8, Store: "B", Price: 10}},
+	}
+
+	priceConfiguration(c, equivalents)
+
+	if c.TotalPrice != 400 {
+		t.Errorf("TotalPrice = %d, want 400", c.TotalPrice)
+	}
+	if want := int64(280 + 2*50 + 3*10); c.CheapestTotalPrice != want {
+		t.Errorf("CheapestTotalPrice = %d, want %d", c.CheapestTotalPrice, want)
+	}
+	if c.CategoryTotals["CPU"] != 300 || c.CategoryTotals["RAM"] != 100 || c.CategoryTotals["Cooling"] != 0 {
+		t.Errorf("CategoryTotals = %v", c.CategoryTotals)
+	}
+	if c.StoreTotals["A"] != 300 || c.StoreTotals["B"] != 100 {
+		t.Errorf("StoreTotals = %v", c.StoreTotals)
+	}
+	if len(c.Warnings) != 1 || c.Warnings[0].ItemID != 11 || c.Warnings[0].Availability != AvailabilityOnOrder {
+		t.Errorf("Warnings = %+v", c.Warnings)
+	}
+}
+
+func TestCheapestListing(t *testing.T) {
+	p := &Product{ID: 1, Price: 100}
+	tests := []struct {
+		name        string
+		p           *Product
+		equivalents []*Product
+		want        int
+	}{
+		{"no equivalents", p, nil, 1},
+		{"cheaper equivalent", p, []*Product{{ID: 2, Price: 90}, {ID: 3, Price: 95}}, 2},
+		{"unpriced equivalent", p, []*Product{{ID: 2, Price: 0}}, 1},
+		{"out of stock equivalent", p, []*Product{{ID: 2, Price: 50, Availability: AvailabilityOutOfStock}}, 1},
+		{"unpriced listing", &Product{ID: 1}, []*Product{{ID: 2, Price: 120}, {ID: 3, Price: 0}}, 2},
+	}
+	for _, tt := range tests {
+		if got := cheapestListing(tt.p, tt.equivalents); got.ID != tt.want {
+			t.Errorf("%s: got product %d, want %d", tt.name, got.ID, tt.want)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+)
+
+// productCursor is the position of the last product on a page, used for
+// keyset pagination. It is handed to clients as an opaque token.
+type productCursor struct {
+	Sort  string `json:"s"`
+	Price int64  `json:"p,omitempty"`
+	ID    int    `json:"i"`
+}
+
+var productSorts = map[string]string{
+	"":           "id ASC",
+	"price_asc":  "price ASC, id ASC",
+	"price_desc": "price DESC, id DESC",
+}
+
+func encodeProductCursor(sort string, p *Product) string {
+	c := productCursor{Sort: sort, ID: p.ID}
+	if sort != "" {
+		c.Price = p.Price
+	}
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeProductCursor(token, sort string) (*productCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	c := new(productCursor)
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	if c.Sort != sort {
+		return nil, fmt.Errorf("cursor does not match sort order")
+	}
+	return c, nil
+}
+
+// keysetCondition adds the condition selecting rows after the cursor for
+// its sort order to f.
+func (c *productCursor) keysetCondition(f *productFilter) {
+	switch c.Sort {
+	case "price_asc":
+		f.query += fmt.Sprintf(" AND (price, id) > (%s, %s)", f.nextArg(c.Price), f.nextArg(c.ID))
+	case "price_desc":
+		f.query += fmt.Sprintf(" AND (price, id) < (%s, %s)", f.nextArg(c.Price), f.nextArg(c.ID))
+	default:
+		f.add("id > %s", c.ID)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import "testing"
+
+func TestProductCursorRoundTrip(t *testing.T) {
+	p := &Product{ID: 42, Price: 12990}
+	tests := []struct {
+		sort      string
+		wantPrice int64
+	}{
+		{"", 0},
+		{"price_asc", 12990},
+		{"price_desc", 12990},
+	}
+	for _, tt := range tests {
+		c, err := decodeProductCursor(encodeProductCursor(tt.sort, p), tt.sort)
+		if err != nil {
+			t.Fatalf("sort %q: decode: %v", tt.sort, err)
+		}
+		if c.ID != 42 || c.Price != tt.wantPrice || c.Sort != tt.sort {
+			t.Errorf("sort %q: got %+v", tt.sort, c)
+		}
+	}
+}
+
+func TestDecodeProductCursorRejects(t *testing.T) {
+	tests := []struct {
+		name  string
+		token string
+		sort  string
+	}{
+		{"not base64", "%%%", ""},
+		{"not json", "bm90IGpzb24", ""},
+		{"other sort", encodeProductCursor("price_asc", &Product{ID: 1}), "price_desc"},
+	}
+	for _, tt := range tests {
+		if _, err := decodeProductCursor(tt.token, tt.sort); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+}
+
+func TestKeysetCondition(t *testing.T) {
+	tests := []struct {
+		cursor productCursor
+		want   string
+		args   int
+	}{
+		{productCursor{ID: 7}, " AND id > $1", 1},
+		{productCursor{Sort: "price_asc", Price: 100, ID: 7}, " AND (price, id) > ($1, $2)", 2},
+		{productCursor{Sort: "price_desc", Price: 100, ID: 7}, " AND (price, id) < ($1, $2)", 2},
+	}
+	for _, tt := range tests {
+		f := &productFilter{}
+		tt.cursor.keysetCondition(f)
+		if f.query != tt.want || len(f.args) != tt.args {
+			t.Errorf("sort %q: got %q with %d args, want %q with %d", tt.cursor.Sort, f.query, len(f.args), tt.want, tt.args)
+		}
+	}
+}
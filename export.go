@@ -0,0 +1,231 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type exportFormat struct {
+	contentType string
+	extension   string
+	attachment  bool
+	write       func(io.Writer, *ComputerConfiguration) error
+}
+
+var exportFormats = map[string]exportFormat{
+	"csv":  {"text/csv; charset=utf-8", "csv", true, writeConfigurationCSV},
+	"json": {"application/json", "json", true, writeConfigurationJSON},
+	"md":   {"text/markdown; charset=utf-8", "md", true, writeConfigurationMarkdown},
+	"html": {"text/html; charset=utf-8", "html", false, writeConfigurationHTML},
+}
+
+func (s *APIServer) handleExportConfiguration(w http.ResponseWriter, r *http.Request) error {
+	config, err := s.getOwnedConfiguration(r)
+	if err != nil {
+		return err
+	}
+	return writeConfigurationExport(w, r, config)
+}
+
+func (s *APIServer) handleExportSharedBuild(w http.ResponseWriter, r *http.Request) error {
+	config, err := s.getSharedConfiguration(r)
+	if err != nil {
+		return err
+	}
+	return writeConfigurationExport(w, r, config)
+}
+
+func writeConfigurationExport(w http.ResponseWriter, r *http.Request, config *ComputerConfiguration) error {
+	name := r.URL.Query().Get("format")
+	if name == "" {
+		name = "json"
+	}
+	format, ok := exportFormats[name]
+	if !ok {
+		return statusErrorf(http.StatusUnprocessableEntity, "unsupported export format %q", name)
+	}
+
+	disposition := "inline"
+	if format.attachment {
+		disposition = "attachment"
+	}
+	w.Header().Set("Content-Type", format.contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("%s; filename=\"configuration-%d.%s\"", disposition, config.ID, format.extension))
+	w.WriteHeader(http.StatusOK)
+	return format.write(w, config)
+}
+
+// exportRow is one line of an exported parts list.
+type exportRow struct {
+	Slot      string `json:"slot,omitempty"`
+	Title     string `json:"title"`
+	Store     string `json:"store"`
+	Code      string `json:"code"`
+	Price     int64  `json:"price"`
+	Quantity  int    `json:"quantity"`
+	Subtotal  int64  `json:"subtotal"`
+	Link      string `json:"link"`
+	Warranty  int64  `json:"warranty"`
+	ProductID int    `json:"productID"`
+}
+
+func exportRows(config *ComputerConfiguration) []exportRow {
+	rows := make([]exportRow, len(config.Items))
+	for i, item := range config.Items {
+		p := item.Product
+		rows[i] = exportRow{
+			Slot:      item.Slot,
+			Title:     p.Title,
+			Store:     p.Store,
+			Code:      p.Code,
+			Price:     p.Price,
+			Quantity:  item.Quantity,
+			Subtotal:  p.Price * int64(item.Quantity),
+			Link:      p.Link,
+			Warranty:  p.Warranty,
+			ProductID: p.ID,
+		}
+	}
+	return rows
+}
+
+func writeConfigurationCSV(w io.Writer, config *ComputerConfiguration) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"slot", "title", "store", "code", "price", "quantity", "subtotal", "link", "warranty"})
+	for _, row := range exportRows(config) {
+		cw.Write([]string{
+			row.Slot,
+			row.Title,
+			row.Store,
+			row.Code,
+			strconv.FormatInt(row.Price, 10),
+			strconv.Itoa(row.Quantity),
+			strconv.FormatInt(row.Subtotal, 10),
+			row.Link,
+			strconv.FormatInt(row.Warranty, 10),
+		})
+	}
+	cw.Write([]string{"", "Total", "", "", "", "", strconv.FormatInt(config.TotalPrice, 10), "", ""})
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeConfigurationJSON(w io.Writer, config *ComputerConfiguration) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(struct {
+		Name       string      `json:"name"`
+		Items      []exportRow `json:"items"`
+		TotalPrice int64       `json:"totalPrice"`
+	}{
+		Name:       config.Name,
+		Items:      exportRows(config),
+		TotalPrice: config.TotalPrice,
+	})
+}
+
+// writeConfigurationMarkdown writes the parts list as a Markdown table that
+// renders on most forums.
+func writeConfigurationMarkdown(w io.Writer, config *ComputerConfiguration) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "## %s\n\n", markdownEscape(config.Name))
+	b.WriteString("| Part | Store | Code | Qty | Price | Warranty |\n")
+	b.WriteString("|---|---|---|---:|---:|---:|\n")
+	for _, row := range exportRows(config) {
+		title := markdownEscape(row.Title)
+		if row.Link != "" {
+			title = fmt.Sprintf("[%s](%s)", title, markdownURLReplacer.Replace(row.Link))
+		}
+		if row.Slot != "" {
+			title = fmt.Sprintf("**%s**: %s", row.Slot, title)
+		}
+		fmt.Fprintf(&b, "| %s | %s | %s | %d | %s | %s |\n",
+			title, markdownEscape(row.Store), markdownEscape(row.Code), row.Quantity, formatPrice(row.Subtotal), formatWarranty(row.Warranty))
+	}
+	fmt.Fprintf(&b, "| **Total** | | | | **%s** | |\n", formatPrice(config.TotalPrice))
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+var markdownReplacer = strings.NewReplacer("|", "\\|", "[", "\\[", "]", "\\]", "*", "\\*", "_", "\\_")
+
+// markdownURLReplacer percent-encodes the characters that would end a link
+// destination or a table cell early.
+var markdownURLReplacer = strings.NewReplacer(" ", "%20", "(", "%28", ")", "%29", "<", "%3C", ">", "%3E", "|", "%7C")
+
+func markdownEscape(s string) string {
+	return markdownReplacer.Replace(s)
+}
+
+var configurationHTML = template.Must(template.New("configuration").Funcs(template.FuncMap{
+	"price":    formatPrice,
+	"warranty": formatWarranty,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Name}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; width: 100%; }
+th, td { border: 1px solid #ccc; padding: 6px 8px; text-align: left; }
+td.num, th.num { text-align: right; }
+tfoot td { font-weight: bold; }
+@media print { a { color: inherit; text-decoration: none; } }
+</style>
+</head>
+<body>
+<h1>{{.Name}}</h1>
+<table>
+<thead><tr><th>Part</th><th>Store</th><th>Code</th><th class="num">Qty</th><th class="num">Price</th><th class="num">Warranty</th></tr></thead>
+<tbody>
+{{range .Rows}}<tr><td>{{if .Slot}}<strong>{{.Slot}}</strong>: {{end}}{{if .Link}}<a href="{{.Link}}">{{.Title}}</a>{{else}}{{.Title}}{{end}}</td><td>{{.Store}}</td><td>{{.Code}}</td><td class="num">{{.Quantity}}</td><td class="num">{{price .Subtotal}}</td><td class="num">{{warranty .Warranty}}</td></tr>
+{{end}}</tbody>
+<tfoot><tr><td colspan="4">Total</td><td class="num">{{price .TotalPrice}}</td><td></td></tr></tfoot>
+</table>
+</body>
+</html>
+`))
+
+func writeConfigurationHTML(w io.Writer, config *ComputerConfiguration) error {
+	return configurationHTML.Execute(w, struct {
+		Name       string
+		Rows       []exportRow
+		TotalPrice int64
+	}{
+		Name:       config.Name,
+		Rows:       exportRows(config),
+		TotalPrice: config.TotalPrice,
+	})
+}
+
+// formatPrice formats a price in denars with thousands separators.
+func formatPrice(price int64) string {
+	digits := strconv.FormatInt(price, 10)
+	sign := ""
+	if strings.HasPrefix(digits, "-") {
+		sign, digits = "-", digits[1:]
+	}
+	var b strings.Builder
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteByte('.')
+		}
+		b.WriteRune(d)
+	}
+	return sign + b.String() + " ден."
+}
+
+func formatWarranty(months int64) string {
+	if months <= 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%d mo.", months)
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWriteConfigurationMarkdown(t *testing.T) {
+	config := &ComputerConfiguration{
+		Name: "Test build",
+		Items: []*ConfigurationItem{{
+			Slot:     "gpu",
+			Quantity: 1,
+			Product: &Product{
+				Title: "RTX 4060 | 8GB",
+				Store: "Anhoch",
+				Code:  "GV-N4060",
+				Price: 18990,
+				Link:  "https://example.com/gpu (new)/rtx 4060",
+			},
+		}},
+		TotalPrice: 18990,
+	}
+
+	var b strings.Builder
+	if err := writeConfigurationMarkdown(&b, config); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+
+	if !strings.Contains(out, "| Part | Store | Code | Qty | Price | Warranty |") {
+		t.Errorf("missing code column:\n%s", out)
+	}
+	if !strings.Contains(out, "(https://example.com/gpu%20%28new%29/rtx%204060)") {
+		t.Errorf("link not escaped:\n%s", out)
+	}
+	if !strings.Contains(out, "RTX 4060 \\| 8GB") || !strings.Contains(out, "| GV-N4060 |") {
+		t.Errorf("unexpected row:\n%s", out)
+	}
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	generatorCandidatesLimit = 1000
+	minBuildBudget           = 5000
+)
+
+// buildProfile describes which components a use case needs and how the
+// budget is split between them. Kinds are chosen in order, so parts that
+// constrain others (CPU, motherboard) come first. The PSU is always chosen
+// last, once the power draw of the other parts is known.
+type buildProfile struct {
+	kinds                   []string
+	weights                 map[string]float64
+	upgrades                []string
+	needsIntegratedGraphics bool
+}
+
+var buildProfiles = map[string]buildProfile{
+	"gaming": {
+		kinds: []string{"cpu", "motherboard", "ram", "gpu", "storage", "case"},
+		weights: map[string]float64{
+			"cpu": 0.20, "motherboard": 0.12, "ram": 0.08, "gpu": 0.38, "storage": 0.08, "case": 0.07, "psu": 0.07,
+		},
+		upgrades: []string{"gpu", "cpu", "storage", "ram"},
+	},
+	"office": {
+		kinds: []string{"cpu", "motherboard", "ram", "storage", "case"},
+		weights: map[string]float64{
+			"cpu": 0.30, "motherboard": 0.20, "ram": 0.15, "storage": 0.15, "case": 0.10, "psu": 0.10,
+		},
+		upgrades:                []string{"storage", "ram", "cpu"},
+		needsIntegratedGraphics: true,
+	},
+	"workstation": {
+		kinds: []string{"cpu", "motherboard", "ram", "gpu", "storage", "case"},
+		weights: map[string]float64{
+			"cpu": 0.28, "motherboard": 0.12, "ram": 0.14, "gpu": 0.20, "storage": 0.12, "case": 0.07, "psu": 0.07,
+		},
+		upgrades: []string{"cpu", "ram", "storage", "gpu"},
+	},
+}
+
+type BuildPreferences struct {
+	CPUBrand string   `json:"cpuBrand"`
+	Stores   []string `json:"stores"`
+}
+
+type GeneratedPart struct {
+	Slot    string   `json:"slot"`
+	Product *Product `json:"product"`
+}
+
+type GeneratedBuild struct {
+	Profile    string           `json:"profile"`
+	Budget     int64            `json:"budget"`
+	TotalPrice int64            `json:"totalPrice"`
+	Remaining  int64            `json:"remaining"`
+	Parts      []*GeneratedPart `json:"parts"`
+	Power      *PowerEstimate   `json:"power"`
+	ConfigID   int              `json:"configID,omitempty"`
+}
+
+// buildGenerator picks one product per component kind. The catalog has no
+// benchmark data, so within a price allowance the most expensive compatible
+// product is taken as the best one.
+type buildGenerator struct {
+	profile     buildProfile
+	preferences BuildPreferences
+	candidates  map[string][]*Product
+	chosen      map[string]*Product
+}
+
+// loadCandidates fetches the products of each needed kind that fit in the
+// budget, cheapest first, keeping to the preferred stores when they stock
+// the kind at all.
+func (s *APIServer) loadCandidates(kinds []string, stores []string, budget int64) (map[string][]*Product, error) {
+	candidates := map[string][]*Product{}
+	for _, kind := range kinds {
+		var products []*Product
+		var err error
+		if len(stores) > 0 {
+			if products, err = s.store.GetComponentCandidates(kind, stores, budget, generatorCandidatesLimit); err != nil {
+				return nil, err
+			}
+		}
+		if len(products) == 0 {
+			if products, err = s.store.GetComponentCandidates(kind, nil, budget, generatorCandidatesLimit); err != nil {
+				return nil, err
+			}
+		}
+		candidates[kind] = products
+	}
+	return candidates, nil
+}
+
+func (g *buildGenerator) compatible(kind string, p *Product, minPSUWatts int) bool {
+	switch kind {
+	case "cpu":
+		socket := cpuSocket(p)
+		if socket == "" {
+			return false
+		}
+		if g.preferences.CPUBrand != "" && cpuBrand(p) != g.preferences.CPUBrand {
+			return false
+		}
+		if g.profile.needsIntegratedGraphics && !hasIntegratedGraphics(p) {
+			return false
+		}
+		if board := g.chosen["motherboard"]; board != nil {
+			return boardSocket(board) == socket
+		}
+		// Only take CPUs some motherboard in the catalog can hold.
+		for _, board := range g.candidates["motherboard"] {
+			if boardSocket(board) == socket {
+				return true
+			}
+		}
+		return false
+	case "motherboard":
+		cpu := g.chosen["cpu"]
+		return cpu != nil && boardSocket(p) == cpuSocket(cpu)
+	case "ram":
+		board := g.chosen["motherboard"]
+		return board == nil || memoryType(board) == "" || memoryType(p) == memoryType(board)
+	case "psu":
+		return psuWattage(p) >= minPSUWatts
+	}
+	return true
+}
+
+// pick returns the most expensive compatible candidate within allowance, or
+// the cheapest compatible one if none fits.
+func (g *buildGenerator) pick(kind string, allowance int64, minPSUWatts int) *Product {
+	candidates := g.candidates[kind]
+	for i := len(candidates) - 1; i >= 0; i-- {
+		if p := candidates[i]; p.Price <= allowance && g.compatible(kind, p, minPSUWatts) {
+			return p
+		}
+	}
+	for _, p := range candidates {
+		if g.compatible(kind, p, minPSUWatts) {
+			return p
+		}
+	}
+	return nil
+}
+
+func (g *buildGenerator) spent() int64 {
+	var total int64
+	for _, p := range g.chosen {
+		total += p.Price
+	}
+	return total
+}
+
+func (g *buildGenerator) configuration() *ComputerConfiguration {
+	c := &ComputerConfiguration{}
+	for _, kind := range append(g.profile.kinds, "psu") {
+		if p := g.chosen[kind]; p != nil {
+			c.Items = append(c.Items, &ConfigurationItem{ProductID: p.ID, Slot: kind, Quantity: 1, Product: p})
+		}
+	}
+	return c
+}
+
+func (g *buildGenerator) generate(budget int64) error {
+	psuReserve := int64(float64(budget) * g.profile.weights["psu"])
+	spendable := budget - psuReserve
+
+	for i, kind := range g.profile.kinds {
+		remainingWeight := 0.0
+		for _, k := range g.profile.kinds[i:] {
+			remainingWeight += g.profile.weights[k]
+		}
+		allowance := int64(float64(spendable-g.spent()) * g.profile.weights[kind] / remainingWeight)
+
+		p := g.pick(kind, allowance, 0)
+		if p == nil {
+			return statusErrorf(http.StatusUnprocessableEntity, "no compatible %s found in the catalog", kind)
+		}
+		g.chosen[kind] = p
+	}
+
+	// Spend what is left on the parts that matter most for the profile.
+	// When over budget the same step trades parts down instead.
+	for _, kind := range g.profile.upgrades {
+		current := g.chosen[kind]
+		delete(g.chosen, kind)
+		if p := g.pick(kind, spendable-g.spent(), 0); p != nil {
+			current = p
+		}
+		g.chosen[kind] = current
+	}
+
+	minWatts := estimatePower(g.configuration()).RecommendedPSUWatts
+	psu := g.pick("psu", budget-g.spent(), minWatts)
+	if psu == nil {
+		return statusErrorf(http.StatusUnprocessableEntity, "no power supply of at least %dW found in the catalog", minWatts)
+	}
+	g.chosen["psu"] = psu
+
+	if total := g.spent(); total > budget {
+		return statusErrorf(http.StatusUnprocessableEntity, "budget is too low, the cheapest compatible build costs %s", formatPrice(total))
+	}
+	return nil
+}
+
+func (s *APIServer) handleGenerateBuild(w http.ResponseWriter, r *http.Request) error {
+	var req struct {
+		Budget      int64            `json:"budget"`
+		Profile     string           `json:"profile"`
+		Preferences BuildPreferences `json:"preferences"`
+		Save        bool             `json:"save"`
+		Name        string           `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return err
+	}
+
+	if req.Profile == "" {
+		req.Profile = "gaming"
+	}
+	profile, ok := buildProfiles[req.Profile]
+	if !ok {
+		return statusErrorf(http.StatusUnprocessableEntity, "profile must be one of gaming, office or workstation")
+	}
+	if req.Budget < minBuildBudget {
+		return statusErrorf(http.StatusUnprocessableEntity, "budget must be at least %s", formatPrice(minBuildBudget))
+	}
+	req.Preferences.CPUBrand = strings.ToLower(req.Preferences.CPUBrand)
+	switch req.Preferences.CPUBrand {
+	case "", "amd", "intel":
+	default:
+		return statusErrorf(http.StatusUnprocessableEntity, "cpuBrand must be amd or intel")
+	}
+
+	userID := 0
+	if req.Save {
+		var err error
+		if userID, err = s.userIDFromRequest(r); err != nil {
+			return err
+		}
+	}
+
+	candidates, err := s.loadCandidates(append(profile.kinds, "psu"), req.Preferences.Stores, req.Budget)
+	if err != nil {
+		return fmt.Errorf("could not load catalog: %w", err)
+	}
+
+	g := &buildGenerator{
+		profile:     profile,
+		preferences: req.Preferences,
+		candidates:  candidates,
+		chosen:      map[string]*Product{},
+	}
+	if err := g.generate(req.Budget); err != nil {
+		return err
+	}
+
+	config := g.configuration()
+	build := &GeneratedBuild{
+		Profile:    req.Profile,
+		Budget:     req.Budget,
+		TotalPrice: g.spent(),
+		Remaining:  req.Budget - g.spent(),
+		Power:      estimatePower(config),
+	}
+	for _, item := range config.Items {
+		build.Parts = append(build.Parts, &GeneratedPart{Slot: item.Slot, Product: item.Product})
+	}
+
+	if req.Save {
+		if req.Name == "" {
+			req.Name = fmt.Sprintf("Generated %s build", req.Profile)
+		}
+		name, err := validateConfigurationName(req.Name)
+		if err != nil {
+			return err
+		}
+		if build.ConfigID, err = s.store.CreateConfigurationWithItems(userID, name, config.Items, "generate"); err != nil {
+			return fmt.Errorf("failed to save build: %w", err)
+		}
+	}
+
+	return WriteJSON(w, http.StatusOK, build)
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+func (s *PostgressStore) CreateConfigurationRevisionsTable() error {
+	_, err := s.db.Exec(`
+    CREATE TABLE IF NOT EXISTS configuration_revisions (
+        id SERIAL PRIMARY KEY,
+        configuration_id INTEGER NOT NULL REFERENCES computer_configurations(id) ON DELETE CASCADE,
+        actor_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+        action TEXT NOT NULL,
+        items JSONB NOT NULL,
+        total_price BIGINT NOT NULL,
+        created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+    );
+    CREATE INDEX IF NOT EXISTS configuration_revisions_configuration_idx
+        ON configuration_revisions (configuration_id, id);
+    `)
+	return err
+}
+
+// recordConfigurationRevision snapshots the current items of a configuration,
+// with the prices they had at that moment. It runs in the transaction of the
+// change being recorded.
+func recordConfigurationRevision(tx *sql.Tx, configID, actorID int, action string) error {
+	_, err := tx.Exec(`
+        INSERT INTO configuration_revisions (configuration_id, actor_id, action, items, total_price)
+        SELECT $1, NULLIF($2, 0), $3,
+               COALESCE(jsonb_agg(jsonb_build_object(
+                   'productID', ci.product_id,
+                   'slot', ci.slot,
+                   'quantity', ci.quantity,
+                   'price', p.price,
+                   'title', p.title,
+                   'store', p.store
+               ) ORDER BY ci.id), '[]'::jsonb),
+               COALESCE(SUM(p.price * ci.quantity), 0)
+        FROM configuration_items ci
+        JOIN products p ON p.id = ci.product_id
+        WHERE ci.configuration_id = $1
+    `, configID, actorID, action)
+	return err
+}
+
+func scanIntoRevision(row rowScanner) (*ConfigurationRevision, error) {
+	rev := new(ConfigurationRevision)
+	var actorID sql.NullInt64
+	var items []byte
+	if err := row.Scan(&rev.ID, &rev.ConfigurationID, &actorID, &rev.Action, &items, &rev.TotalPrice, &rev.CreatedAt); err != nil {
+		return nil, err
+	}
+	if actorID.Valid {
+		id := int(actorID.Int64)
+		rev.ActorID = &id
+	}
+	if err := json.Unmarshal(items, &rev.Items); err != nil {
+		return nil, err
+	}
+	return rev, nil
+}
+
+const revisionColumns = "id, configuration_id, actor_id, action, items, total_price, created_at"
+
+func (s *PostgressStore) GetConfigurationRevisions(configID int) ([]*ConfigurationRevision, error) {
+	rows, err := s.db.Query(`
+        SELECT `+revisionColumns+`
+        FROM configuration_revisions
+        WHERE configuration_id = $1
+        ORDER BY id DESC
+    `, configID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []*ConfigurationRevision{}
+	for rows.Next() {
+		rev, err := scanIntoRevision(rows)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, rev)
+	}
+	return revisions, rows.Err()
+}
+
+func (s *PostgressStore) GetConfigurationRevision(configID, revisionID int) (*ConfigurationRevision, error) {
+	rev, err := scanIntoRevision(s.db.QueryRow(`
+        SELECT `+revisionColumns+`
+        FROM configuration_revisions
+        WHERE configuration_id = $1 AND id = $2
+    `, configID, revisionID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return rev, err
+}
+
+// RestoreConfigurationRevision replaces the items of a configuration with
+// those of the revision. Products that no longer exist are skipped.
+func (s *PostgressStore) RestoreConfigurationRevision(configID, revisionID, actorID int) error {
+	return s.changeConfiguration(configID, actorID, "restore", func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`DELETE FROM configuration_items WHERE configuration_id = $1`, configID); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`
+            INSERT INTO configuration_items (configuration_id, product_id, quantity, slot)
+            SELECT r.configuration_id, (i->>'productID')::int, (i->>'quantity')::int, i->>'slot'
+            FROM configuration_revisions r
+            CROSS JOIN jsonb_array_elements(r.items) i
+            JOIN products p ON p.id = (i->>'productID')::int
+            WHERE r.configuration_id = $1 AND r.id = $2
+        `, configID, revisionID)
+		return err
+	})
+}
+
+type RevisionDiff struct {
+	From       int             `json:"from"`
+	To         int             `json:"to"`
+	Added      []*RevisionItem `json:"added"`
+	Removed    []*RevisionItem `json:"removed"`
+	PriceDelta int64           `json:"priceDelta"`
+}
+
+// diffRevisions lists the parts added and removed between two revisions.
+// A quantity change shows up as the difference in units.
+func diffRevisions(from, to *ConfigurationRevision) *RevisionDiff {
+	type key struct {
+		productID int
+		slot      string
+	}
+	var keys []key
+	items := map[key]*RevisionItem{}
+	delta := map[key]int{}
+	for _, side := range []struct {
+		rev  *ConfigurationRevision
+		sign int
+	}{{to, 1}, {from, -1}} {
+		for _, item := range side.rev.Items {
+			k := key{item.ProductID, item.Slot}
+			if _, seen := items[k]; !seen {
+				keys = append(keys, k)
+				items[k] = item
+			}
+			delta[k] += side.sign * item.Quantity
+		}
+	}
+
+	diff := &RevisionDiff{
+		From:       from.ID,
+		To:         to.ID,
+		Added:      []*RevisionItem{},
+		Removed:    []*RevisionItem{},
+		PriceDelta: to.TotalPrice - from.TotalPrice,
+	}
+	for _, k := range keys {
+		change := *items[k]
+		switch d := delta[k]; {
+		case d > 0:
+			change.Quantity = d
+			diff.Added = append(diff.Added, &change)
+		case d < 0:
+			change.Quantity = -d
+			diff.Removed = append(diff.Removed, &change)
+		}
+	}
+	return diff
+}
+
+func (s *APIServer) handleGetConfigurationHistory(w http.ResponseWriter, r *http.Request) error {
+	config, err := s.getOwnedConfiguration(r)
+	if err != nil {
+		return err
+	}
+
+	revisions, err := s.store.GetConfigurationRevisions(config.ID)
+	if err != nil {
+		return fmt.Errorf("could not get history: %w", err)
+	}
+	return WriteJSON(w, http.StatusOK, revisions)
+}
+
+func (s *APIServer) handleRestoreConfigurationRevision(w http.ResponseWriter, r *http.Request) error {
+	config, err := s.getOwnedConfiguration(r)
+	if err != nil {
+		return err
+	}
+
+	rev, err := s.getRevision(config.ID, mux.Vars(r)["revisionID"])
+	if err != nil {
+		return err
+	}
+
+	if err := s.store.RestoreConfigurationRevision(config.ID, rev.ID, config.UserID); err != nil {
+		return fmt.Errorf("failed to restore revision: %w", err)
+	}
+
+	return WriteJSON(w, http.StatusOK, map[string]string{"message": "revision restored"})
+}
+
+func (s *APIServer) handleDiffConfigurationRevisions(w http.ResponseWriter, r *http.Request) error {
+	config, err := s.getOwnedConfiguration(r)
+	if err != nil {
+		return err
+	}
+
+	from, err := s.getRevision(config.ID, r.URL.Query().Get("from"))
+	if err != nil {
+		return err
+	}
+
+	var to *ConfigurationRevision
+	if v := r.URL.Query().Get("to"); v != "" {
+		if to, err = s.getRevision(config.ID, v); err != nil {
+			return err
+		}
+	} else {
+		revisions, err := s.store.GetConfigurationRevisions(config.ID)
+		if err != nil {
+			return fmt.Errorf("could not get history: %w", err)
+		}
+		if len(revisions) == 0 {
+			return statusErrorf(http.StatusNotFound, "configuration has no history")
+		}
+		to = revisions[0]
+	}
+
+	return WriteJSON(w, http.StatusOK, diffRevisions(from, to))
+}
+
+func (s *APIServer) getRevision(configID int, idStr string) (*ConfigurationRevision, error) {
+	revisionID, err := strconv.Atoi(idStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid revision ID")
+	}
+
+	rev, err := s.store.GetConfigurationRevision(configID, revisionID)
+	if err != nil {
+		return nil, fmt.Errorf("could not get revision: %w", err)
+	}
+	if rev == nil {
+		return nil, statusErrorf(http.StatusNotFound, "revision not found")
+	}
+	return rev, nil
+}
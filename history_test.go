@@ -0,0 +1,56 @@
+package main
+
+import "testing"
+
+func TestDiffRevisions(t *testing.T) {
+	from := &ConfigurationRevision{
+		ID: 1,
+		Items: []*RevisionItem{
+			{ProductID: 1, Slot: "cpu", Quantity: 1, Price: 100},
+			{ProductID: 2, Quantity: 2, Price: 20},
+			{ProductID: 3, Slot: "gpu", Quantity: 1, Price: 300},
+		},
+		TotalPrice: 440,
+	}
+	to := &ConfigurationRevision{
+		ID: 2,
+		Items: []*RevisionItem{
+			{ProductID: 1, Slot: "cpu", Quantity: 1, Price: 100},
+			{ProductID: 2, Quantity: 4, Price: 20},
+			{ProductID: 4, Slot: "gpu", Quantity: 1, Price: 350},
+		},
+		TotalPrice: 530,
+	}
+
+	diff := diffRevisions(from, to)
+	if diff.From != 1 || diff.To != 2 || diff.PriceDelta != 90 {
+		t.Errorf("got %+v", diff)
+	}
+
+	type change struct{ productID, quantity int }
+	check := func(name string, items []*RevisionItem, want []change) {
+		if len(items) != len(want) {
+			t.Fatalf("%s: got %d items, want %d", name, len(items), len(want))
+		}
+		for i, item := range items {
+			if item.ProductID != want[i].productID || item.Quantity != want[i].quantity {
+				t.Errorf("%s[%d] = product %d x%d, want product %d x%d",
+					name, i, item.ProductID, item.Quantity, want[i].productID, want[i].quantity)
+			}
+		}
+	}
+	check("added", diff.Added, []change{{2, 2}, {4, 1}})
+	check("removed", diff.Removed, []change{{3, 1}})
+
+	if from.Items[1].Quantity != 2 {
+		t.Error("diffRevisions modified its input")
+	}
+}
+
+func TestDiffRevisionsUnchanged(t *testing.T) {
+	rev := &ConfigurationRevision{Items: []*RevisionItem{{ProductID: 1, Quantity: 1}}}
+	diff := diffRevisions(rev, rev)
+	if len(diff.Added) != 0 || len(diff.Removed) != 0 || diff.PriceDelta != 0 {
+		t.Errorf("got %+v", diff)
+	}
+}
@@ -5,27 +5,34 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 )
 
-func ImportProductsFromCSV(store Storage, path string) error {
+// ImportProductsFromCSV creates or updates the products listed in the CSV
+// file and returns the listings whose price changed. Rows have ten columns,
+// optionally followed by availability and a delivery estimate.
+func ImportProductsFromCSV(store Storage, path string) ([]*PriceChange, error) {
 	file, err := os.Open(path)
 	if err != nil {
-		return fmt.Errorf("could not open CSV file: %w", err)
+		return nil, fmt.Errorf("could not open CSV file: %w", err)
 	}
 	defer file.Close()
 
 	reader := csv.NewReader(file)
 	records, err := reader.ReadAll()
 	if err != nil {
-		return fmt.Errorf("could not read CSV: %w", err)
+		return nil, fmt.Errorf("could not read CSV: %w", err)
 	}
 
+	var changes []*PriceChange
+	imported := map[string]bool{}
+
 	for i, row := range records {
 		if i == 0 {
 			continue
 		}
-		if len(row) != 10 {
-			return fmt.Errorf("row %d has wrong number of columns", i+1)
+		if len(row) < 10 || len(row) > 12 {
+			return nil, fmt.Errorf("row %d has wrong number of columns", i+1)
 		}
 
 		price, _ := strconv.ParseInt(row[2], 10, 64)
@@ -42,12 +49,49 @@ func ImportProductsFromCSV(store Storage, path string) error {
 			Description:  row[7],
 			Image:        row[8],
 			Store:        row[9],
+			Availability: AvailabilityUnknown,
+		}
+		if len(row) > 10 {
+			product.Availability = parseAvailability(row[10])
+		}
+		if len(row) > 11 {
+			product.DeliveryEstimate = strings.TrimSpace(row[11])
 		}
 
-		if err := store.CreateProduct(product); err != nil {
+		change, err := store.UpsertProduct(product)
+		if err != nil {
 			fmt.Printf("failed to insert product at row %d: %v\n", i+1, err)
+			continue
 		}
+		if change != nil {
+			changes = append(changes, change)
+		}
+		if product.Store != "" {
+			imported[product.Store] = true
+		}
+	}
+
+	for name := range imported {
+		if err := store.MarkUnseenListingsOutOfStock(name); err != nil {
+			return nil, fmt.Errorf("could not update stock of %s: %w", name, err)
+		}
+		if err := store.RecordStoreImport(name); err != nil {
+			return nil, fmt.Errorf("could not record import of %s: %w", name, err)
+		}
+	}
+
+	if err := store.RefreshConfigurationTotals(); err != nil {
+		return nil, fmt.Errorf("could not refresh configuration totals: %w", err)
+	}
+	if err := ApplyCategoryTaxonomy(store, nil); err != nil {
+		return nil, fmt.Errorf("could not categorize products: %w", err)
+	}
+	if err := ApplyBrandDirectory(store, nil); err != nil {
+		return nil, fmt.Errorf("could not link products to brands: %w", err)
+	}
+	if err := store.RecordStockSnapshot(); err != nil {
+		return nil, fmt.Errorf("could not record stock history: %w", err)
 	}
 
-	return nil
+	return changes, nil
 }
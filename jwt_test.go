@@ -0,0 +1,76 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestSessionToken(t *testing.T) {
+	token, err := GenerateJWT(&User{ID: 7, TokenVersion: 3, Role: RoleEditor})
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := ParseJWT(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Subject != "7" || claims.TokenVersion != 3 {
+		t.Errorf("claims = %+v", claims)
+	}
+
+	if _, _, err := ParseEmailVerificationToken(token); err == nil {
+		t.Error("session token accepted as a verification token")
+	}
+}
+
+func TestEmailVerificationToken(t *testing.T) {
+	token, err := GenerateEmailVerificationToken(7, "user@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	userID, email, err := ParseEmailVerificationToken(token)
+	if err != nil || userID != 7 || email != "user@example.com" {
+		t.Errorf("ParseEmailVerificationToken = %d, %q, %v", userID, email, err)
+	}
+
+	if _, err := ParseJWT(token); err == nil {
+		t.Error("verification token accepted as a session")
+	}
+}
+
+func TestExpiredTokens(t *testing.T) {
+	expired := jwt.RegisteredClaims{
+		Subject:   "7",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(-time.Minute)),
+	}
+	sign := func(claims jwt.Claims) string {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(jwtKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+
+	if _, err := ParseJWT(sign(&sessionClaims{RegisteredClaims: expired})); err == nil {
+		t.Error("expired session token accepted")
+	}
+
+	expired.Audience = jwt.ClaimStrings{emailVerificationAudience}
+	if _, _, err := ParseEmailVerificationToken(sign(&emailVerificationClaims{Email: "user@example.com", RegisteredClaims: expired})); err == nil {
+		t.Error("expired verification token accepted")
+	}
+}
+
+func TestTokenWithOtherKey(t *testing.T) {
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, &sessionClaims{
+		RegisteredClaims: jwt.RegisteredClaims{Subject: "7", ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))},
+	}).SignedString([]byte("another key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseJWT(token); err == nil {
+		t.Error("token signed with another key accepted")
+	}
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"container/list"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// loginThrottle describes how failed logins against one key (an account or
+// a client IP) are slowed down. The first freeAttempts failures cost
+// nothing; after that each failure doubles the wait before the next attempt,
+// and lockoutAfter consecutive failures lock the key out entirely.
+type loginThrottle struct {
+	freeAttempts int
+	lockoutAfter int
+	lockout      time.Duration
+}
+
+var (
+	accountThrottle = loginThrottle{freeAttempts: 3, lockoutAfter: 10, lockout: 15 * time.Minute}
+	ipThrottle      = loginThrottle{freeAttempts: 10, lockoutAfter: 100, lockout: time.Hour}
+)
+
+const (
+	loginBackoffBase = time.Second
+	loginBackoffMax  = 5 * time.Minute
+	// loginFailureTTL is how long a failure is remembered when no further
+	// attempts are made. Lockouts must not outlast it.
+	loginFailureTTL = time.Hour
+	// loginAttemptTimeout is how long a reserved attempt may take to report
+	// its outcome before it stops counting as in flight.
+	loginAttemptTimeout = time.Minute
+	// maxTrackedLoginKeys bounds the memory used per kind of key; beyond it
+	// the key left alone the longest is forgotten.
+	maxTrackedLoginKeys = 100000
+)
+
+type loginFailures struct {
+	key         string
+	touched     time.Time
+	count       int
+	pending     int
+	lastFailure time.Time
+	reservedAt  time.Time
+	lockedUntil time.Time
+}
+
+// LoginLimiter tracks failed logins in memory, per account and per client
+// IP. State is lost on restart, which only ever makes it more lenient.
+type LoginLimiter struct {
+	mu                sync.Mutex
+	accounts          *loginKeys
+	ips               *loginKeys
+	trustProxyHeaders bool
+	now               func() time.Time
+}
+
+// NewLoginLimiterFromEnv creates a limiter. Set TRUST_PROXY_HEADERS=true
+// when running behind a reverse proxy so client IPs are taken from
+// X-Real-IP/X-Forwarded-For instead of the proxy's own address.
+func NewLoginLimiterFromEnv() *LoginLimiter {
+	// Hash up front so the first unknown-email login isn't the slow one.
+	dummyPasswordHash()
+
+	l := newLoginLimiter(time.Now)
+	l.trustProxyHeaders = os.Getenv("TRUST_PROXY_HEADERS") == "true"
+	return l
+}
+
+func newLoginLimiter(now func() time.Time) *LoginLimiter {
+	return &LoginLimiter{
+		accounts: newLoginKeys(),
+		ips:      newLoginKeys(),
+		now:      now,
+	}
+}
+
+func (f *loginFailures) inFlight(now time.Time) int {
+	if now.Sub(f.reservedAt) > loginAttemptTimeout {
+		return 0
+	}
+	return f.pending
+}
+
+func (t loginThrottle) wait(f *loginFailures, now time.Time) time.Duration {
+	if f == nil {
+		return 0
+	}
+	if now.Before(f.lockedUntil) {
+		return f.lockedUntil.Sub(now)
+	}
+	count := f.count
+	if now.Sub(f.lastFailure) > loginFailureTTL {
+		count = 0
+	}
+	if count+f.inFlight(now) < t.freeAttempts {
+		return 0
+	}
+	// Past the free attempts only one attempt may be in flight at a time,
+	// so parallel requests cannot all slip through before any fails.
+	if f.inFlight(now) > 0 {
+		return loginBackoffBase
+	}
+
+	delay := loginBackoffMax
+	if shift := count - t.freeAttempts; shift < 16 {
+		delay = min(loginBackoffBase<<shift, loginBackoffMax)
+	}
+	return max(f.lastFailure.Add(delay).Sub(now), 0)
+}
+
+// fail records a failure and reports whether it locked the key out.
+func (t loginThrottle) fail(f *loginFailures, now time.Time) bool {
+	f.release()
+	if now.Sub(f.lastFailure) > loginFailureTTL {
+		f.count = 0
+	}
+
+	f.count++
+	f.lastFailure = now
+	if f.count < t.lockoutAfter {
+		return false
+	}
+	f.count = 0
+	f.lockedUntil = now.Add(t.lockout)
+	return true
+}
+
+func (f *loginFailures) release() {
+	if f != nil && f.pending > 0 {
+		f.pending--
+	}
+}
+
+// loginKeys holds the failures of one kind of key, least recently used
+// first, so that expired keys are pruned from the front and a full set
+// forgets the key left alone the longest.
+type loginKeys struct {
+	entries map[string]*list.Element
+	order   list.List
+}
+
+func newLoginKeys() *loginKeys {
+	return &loginKeys{entries: make(map[string]*list.Element)}
+}
+
+func (k *loginKeys) get(key string) *loginFailures {
+	if e := k.entries[key]; e != nil {
+		return e.Value.(*loginFailures)
+	}
+	return nil
+}
+
+// track returns the record of key, creating it if needed, and marks it used.
+func (k *loginKeys) track(key string, now time.Time) *loginFailures {
+	if e := k.entries[key]; e != nil {
+		k.order.MoveToBack(e)
+		f := e.Value.(*loginFailures)
+		f.touched = now
+		return f
+	}
+	if len(k.entries) >= maxTrackedLoginKeys {
+		k.remove(k.order.Front())
+	}
+	f := &loginFailures{key: key, touched: now}
+	k.entries[key] = k.order.PushBack(f)
+	return f
+}
+
+func (k *loginKeys) remove(e *list.Element) {
+	k.order.Remove(e)
+	delete(k.entries, e.Value.(*loginFailures).key)
+}
+
+func (k *loginKeys) forget(key string) {
+	if e := k.entries[key]; e != nil {
+		k.remove(e)
+	}
+}
+
+// prune forgets the keys unused for loginFailureTTL, whose failures and
+// lockouts have all expired.
+func (k *loginKeys) prune(now time.Time) {
+	for e := k.order.Front(); e != nil && now.Sub(e.Value.(*loginFailures).touched) > loginFailureTTL; e = k.order.Front() {
+		k.remove(e)
+	}
+}
+
+// Reserve checks whether the client may try a password for the account now.
+// If so it returns 0 and holds the attempt in flight until Fail or Succeed
+// reports its outcome; otherwise it returns how long to wait.
+func (l *LoginLimiter) Reserve(email, ip string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.accounts.prune(now)
+	l.ips.prune(now)
+	if wait := max(accountThrottle.wait(l.accounts.get(email), now), ipThrottle.wait(l.ips.get(ip), now)); wait > 0 {
+		return wait
+	}
+	for _, f := range []*loginFailures{l.accounts.track(email, now), l.ips.track(ip, now)} {
+		f.pending = f.inFlight(now) + 1
+		f.reservedAt = now
+	}
+	return 0
+}
+
+// Fail records a failed login and reports whether the account and the IP
+// got locked out by it.
+func (l *LoginLimiter) Fail(email, ip string) (accountLocked, ipLocked bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	return accountThrottle.fail(l.accounts.track(email, now), now), ipThrottle.fail(l.ips.track(ip, now), now)
+}
+
+// Succeed forgets the account's failures. The IP keeps its record so one
+// valid account cannot be used to reset guessing against others.
+func (l *LoginLimiter) Succeed(email, ip string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.accounts.forget(email)
+	l.ips.get(ip).release()
+}
+
+// loginKey is the account key of an email address. Login and the current
+// password check must agree on it, also for accounts with legacy mixed-case
+// emails.
+func loginKey(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func (l *LoginLimiter) clientIP(r *http.Request) string {
+	if l.trustProxyHeaders {
+		if ip := strings.TrimSpace(r.Header.Get("X-Real-IP")); ip != "" {
+			return ip
+		}
+		// The last entry is the one appended by our proxy; earlier ones
+		// are supplied by the client and can be forged.
+		if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" {
+			parts := strings.Split(fwd, ",")
+			return strings.TrimSpace(parts[len(parts)-1])
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+var (
+	dummyHashOnce sync.Once
+	dummyHash     []byte
+)
+
+// dummyPasswordHash returns a bcrypt hash with the same cost as real ones,
+// used to spend the same time on logins for unknown emails.
+func dummyPasswordHash() []byte {
+	dummyHashOnce.Do(func() {
+		dummyHash, _ = bcrypt.GenerateFromPassword([]byte("not a real password"), bcrypt.DefaultCost)
+	})
+	return dummyHash
+}
+
+// tooManyLoginAttempts rejects a throttled password check, telling the
+// client when to try again.
+func tooManyLoginAttempts(w http.ResponseWriter, wait time.Duration) error {
+	w.Header().Set("Retry-After", strconv.Itoa(int(wait.Round(time.Second).Seconds())))
+	return statusErrorf(http.StatusTooManyRequests, "too many failed login attempts, please try again later")
+}
+
+// recordFailedLogin counts a failed attempt and writes an audit entry when
+// it locks out the account or the IP. user is nil for unknown emails.
+func (s *APIServer) recordFailedLogin(user *User, email, ip string) {
+	accountLocked, ipLocked := s.loginLimiter.Fail(email, ip)
+	if !accountLocked && !ipLocked {
+		return
+	}
+
+	event := &AuditEvent{
+		Event:   AuditAccountLocked,
+		Email:   email,
+		IP:      ip,
+		Details: map[string]any{"account": accountLocked, "ip": ipLocked},
+	}
+	if user != nil {
+		event.UserID = &user.ID
+	}
+	s.recordAuditEvent(event)
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time          { return c.t }
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+func newTestLoginLimiter() (*LoginLimiter, *fakeClock) {
+	clock := &fakeClock{t: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)}
+	return newLoginLimiter(clock.now), clock
+}
+
+func TestLoginLimiterBackoff(t *testing.T) {
+	l, clock := newTestLoginLimiter()
+
+	for i := 0; i < accountThrottle.freeAttempts; i++ {
+		if wait := l.Reserve("a@example.com", "1.1.1.1"); wait != 0 {
+			t.Fatalf("attempt %d: wait = %v, want 0", i+1, wait)
+		}
+		l.Fail("a@example.com", "1.1.1.1")
+	}
+	if wait := l.Reserve("a@example.com", "1.1.1.1"); wait != loginBackoffBase {
+		t.Fatalf("after free attempts: wait = %v, want %v", wait, loginBackoffBase)
+	}
+
+	clock.advance(loginBackoffBase)
+	if wait := l.Reserve("a@example.com", "1.1.1.1"); wait != 0 {
+		t.Fatalf("after backoff: wait = %v, want 0", wait)
+	}
+	l.Fail("a@example.com", "1.1.1.1")
+	if wait := l.Reserve("a@example.com", "1.1.1.1"); wait != 2*loginBackoffBase {
+		t.Fatalf("second backoff: wait = %v, want %v", wait, 2*loginBackoffBase)
+	}
+
+	if wait := l.Reserve("b@example.com", "1.1.1.1"); wait != 0 {
+		t.Errorf("other account: wait = %v, want 0", wait)
+	}
+}
+
+func TestLoginLimiterLockout(t *testing.T) {
+	l, clock := newTestLoginLimiter()
+
+	var locked bool
+	for i := 0; i < accountThrottle.lockoutAfter; i++ {
+		clock.advance(loginBackoffMax)
+		if wait := l.Reserve("a@example.com", "1.1.1.1"); wait != 0 {
+			t.Fatalf("attempt %d: wait = %v, want 0", i+1, wait)
+		}
+		locked, _ = l.Fail("a@example.com", "1.1.1.1")
+	}
+	if !locked {
+		t.Fatal("account not locked after lockoutAfter failures")
+	}
+	if wait := l.Reserve("a@example.com", "1.1.1.1"); wait != accountThrottle.lockout {
+		t.Errorf("locked: wait = %v, want %v", wait, accountThrottle.lockout)
+	}
+}
+
+func TestLoginLimiterParallelAttempts(t *testing.T) {
+	l, clock := newTestLoginLimiter()
+
+	allowed := 0
+	for i := 0; i < 10; i++ {
+		if l.Reserve("a@example.com", "1.1.1.1") == 0 {
+			allowed++
+		}
+	}
+	if allowed != accountThrottle.freeAttempts {
+		t.Fatalf("parallel attempts allowed = %d, want %d", allowed, accountThrottle.freeAttempts)
+	}
+
+	// A reservation that never reports back stops blocking eventually.
+	clock.advance(loginAttemptTimeout + time.Second)
+	if wait := l.Reserve("a@example.com", "1.1.1.1"); wait != 0 {
+		t.Errorf("after attempt timeout: wait = %v, want 0", wait)
+	}
+}
+
+func TestLoginLimiterSucceed(t *testing.T) {
+	l, _ := newTestLoginLimiter()
+
+	for i := 0; i < ipThrottle.freeAttempts; i++ {
+		email := "user" + strconv.Itoa(i) + "@example.com"
+		l.Reserve(email, "1.1.1.1")
+		l.Fail(email, "1.1.1.1")
+	}
+	l.Reserve("a@example.com", "2.2.2.2")
+	l.Fail("a@example.com", "2.2.2.2")
+
+	l.Reserve("a@example.com", "2.2.2.2")
+	l.Succeed("a@example.com", "2.2.2.2")
+	if l.accounts.get("a@example.com") != nil {
+		t.Error("account failures kept after success")
+	}
+	if wait := l.Reserve("c@example.com", "1.1.1.1"); wait == 0 {
+		t.Error("IP throttle reset by success on another IP")
+	}
+}
+
+func TestLoginLimiterCapsTrackedKeys(t *testing.T) {
+	l, clock := newTestLoginLimiter()
+	for i := 0; i < maxTrackedLoginKeys; i++ {
+		l.accounts.track(strconv.Itoa(i)+"@example.com", clock.now())
+	}
+
+	// A full map must not let a new account escape the throttle.
+	clock.advance(time.Second)
+	for i := 0; i < accountThrottle.freeAttempts; i++ {
+		l.Reserve("target@example.com", "1.1.1."+strconv.Itoa(i))
+		l.Fail("target@example.com", "1.1.1."+strconv.Itoa(i))
+	}
+	if wait := l.Reserve("target@example.com", "2.2.2.2"); wait == 0 {
+		t.Error("new account not throttled when the map is full")
+	}
+	if n := len(l.accounts.entries); n != maxTrackedLoginKeys {
+		t.Errorf("tracked accounts = %d, want %d", n, maxTrackedLoginKeys)
+	}
+	if l.accounts.get("0@example.com") != nil {
+		t.Error("least recently used account not evicted")
+	}
+}
+
+func TestLoginLimiterPrunesExpiredKeys(t *testing.T) {
+	l, clock := newTestLoginLimiter()
+	l.Reserve("a@example.com", "1.1.1.1")
+	l.Fail("a@example.com", "1.1.1.1")
+
+	clock.advance(loginFailureTTL + time.Second)
+	l.Reserve("b@example.com", "2.2.2.2")
+	if l.accounts.get("a@example.com") != nil || l.ips.get("1.1.1.1") != nil {
+		t.Error("expired keys not pruned")
+	}
+	if l.accounts.get("b@example.com") == nil {
+		t.Error("new key pruned")
+	}
+}
+
+func TestLoginKey(t *testing.T) {
+	if loginKey(" Legacy.User@Example.com ") != loginKey("legacy.user@example.com") {
+		t.Error("login and change-password keys differ by case")
+	}
+}
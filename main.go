@@ -2,6 +2,7 @@ package main
 
 import (
 	"log"
+	"os"
 
 	"github.com/joho/godotenv"
 )
@@ -21,22 +22,84 @@ func main() {
 		log.Fatal("Could not create products table:", err)
 	}
 
+	if err := store.CreateCategoryTables(); err != nil {
+		log.Fatal("Could not create category tables:", err)
+	}
+
+	if err := store.CreateBrandTables(); err != nil {
+		log.Fatal("Could not create brand tables:", err)
+	}
+
+	if err := store.CreateStoresTable(); err != nil {
+		log.Fatal("Could not create stores table:", err)
+	}
+
+	if err := store.CreateStockHistoryTable(); err != nil {
+		log.Fatal("Could not create stock history table:", err)
+	}
+
 	if err := store.createUserTable(); err != nil {
 		log.Fatal("Could not create users table:", err)
 	}
 
+	if err := store.CreatePasswordResetTokensTable(); err != nil {
+		log.Fatal("Could not create password reset tokens table:", err)
+	}
+
+	if err := store.CreateAuditLogTable(); err != nil {
+		log.Fatal("Could not create audit log table:", err)
+	}
+
 	if err := store.CreateComputerConfigurationsTable(); err != nil {
 		log.Fatal("Could not create computer configuration table:", err)
 	}
 	if err := store.CreateConfigurationItemsTable(); err != nil {
 		log.Fatal("Could not create computer item configuration table:", err)
 	}
+	if err := store.CreateConfigurationRevisionsTable(); err != nil {
+		log.Fatal("Could not create configuration revisions table:", err)
+	}
+	if err := store.CreatePriceAlertsTable(); err != nil {
+		log.Fatal("Could not create price alerts table:", err)
+	}
+	if err := store.CreateWishlistTables(); err != nil {
+		log.Fatal("Could not create wishlist tables:", err)
+	}
+	if err := store.CreateProductListingIndex(); err != nil {
+		log.Fatal("Could not create product listing index:", err)
+	}
+
+	passwordPolicy, err := LoadPasswordPolicyFromEnv()
+	if err != nil {
+		log.Fatal("Could not load password policy:", err)
+	}
 
-	err = ImportProductsFromCSV(store, "products.csv")
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "create-admin":
+			if err := runCreateAdmin(store, passwordPolicy, os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			log.Printf("%s is now an admin", os.Args[2])
+			return
+		default:
+			log.Fatalf("unknown command %q", os.Args[1])
+		}
+	}
+
+	notifier, err := NewNotifierFromEnv()
+	if err != nil {
+		log.Fatal("Could not set up notifier:", err)
+	}
+
+	changes, err := ImportProductsFromCSV(store, "products.csv")
 	if err != nil {
 		log.Fatalf("CSV import failed: %v", err)
 	}
+	if err := EvaluatePriceAlerts(store, notifier, changes); err != nil {
+		log.Printf("Price alert evaluation failed: %v", err)
+	}
 
-	server := NewAPIServer(":3000", store)
+	server := NewAPIServer(":3000", store, passwordPolicy, notifier, NewLoginLimiterFromEnv())
 	server.Run()
 }
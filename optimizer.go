@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+)
+
+const (
+	defaultMaxStores = 1
+	maxMaxStores     = 4
+)
+
+type StoreAssignment struct {
+	ItemID    int      `json:"itemID"`
+	Slot      string   `json:"slot,omitempty"`
+	Quantity  int      `json:"quantity"`
+	Current   *Product `json:"current"`
+	Suggested *Product `json:"suggested"`
+}
+
+type StoreOptimization struct {
+	MaxStores      int                `json:"maxStores"`
+	Stores         []string           `json:"stores"`
+	CurrentTotal   int64              `json:"currentTotal"`
+	CurrentStores  int                `json:"currentStores"`
+	OptimizedTotal int64              `json:"optimizedTotal"`
+	Difference     int64              `json:"difference"`
+	Assignments    []*StoreAssignment `json:"assignments"`
+}
+
+// optimizeStores finds the set of at most maxStores stores that can supply
+// every item of c, using the listings in equivalents, at the lowest total.
+// Listings without a price or out of stock are not considered. It returns
+// nil when no such set exists.
+func optimizeStores(c *ComputerConfiguration, equivalents map[int][]*Product, maxStores int) *StoreOptimization {
+	options := make([][]*Product, len(c.Items))
+	storeSet := map[string]bool{}
+	currentStores := map[string]bool{}
+	for i, item := range c.Items {
+		options[i] = append([]*Product{item.Product}, equivalents[item.ProductID]...)
+		for _, p := range options[i] {
+			storeSet[p.Store] = true
+		}
+		currentStores[item.Product.Store] = true
+	}
+	stores := make([]string, 0, len(storeSet))
+	for store := range storeSet {
+		stores = append(stores, store)
+	}
+	sort.Strings(stores)
+
+	var best *StoreOptimization
+	var bestPicks []*Product
+	forEachStoreSubset(stores, maxStores, func(subset map[string]bool) {
+		picks := make([]*Product, len(c.Items))
+		var total int64
+		for i, item := range c.Items {
+			for _, p := range options[i] {
+				if p.Price <= 0 || p.Availability == AvailabilityOutOfStock || !subset[p.Store] {
+					continue
+				}
+				if picks[i] == nil || p.Price < picks[i].Price {
+					picks[i] = p
+				}
+			}
+			if picks[i] == nil {
+				return
+			}
+			total += picks[i].Price * int64(item.Quantity)
+		}
+		if best == nil || total < best.OptimizedTotal || (total == best.OptimizedTotal && len(subset) < len(best.Stores)) {
+			used := map[string]bool{}
+			for _, p := range picks {
+				used[p.Store] = true
+			}
+			best = &StoreOptimization{OptimizedTotal: total}
+			for _, store := range stores {
+				if used[store] {
+					best.Stores = append(best.Stores, store)
+				}
+			}
+			bestPicks = picks
+		}
+	})
+	if best == nil {
+		return nil
+	}
+
+	best.MaxStores = maxStores
+	best.CurrentTotal = c.TotalPrice
+	best.CurrentStores = len(currentStores)
+	best.Difference = best.OptimizedTotal - c.TotalPrice
+	best.Assignments = make([]*StoreAssignment, len(c.Items))
+	for i, item := range c.Items {
+		best.Assignments[i] = &StoreAssignment{
+			ItemID:    item.ID,
+			Slot:      item.Slot,
+			Quantity:  item.Quantity,
+			Current:   item.Product,
+			Suggested: bestPicks[i],
+		}
+	}
+	return best
+}
+
+// forEachStoreSubset calls fn with every non-empty subset of stores of at
+// most k elements.
+func forEachStoreSubset(stores []string, k int, fn func(map[string]bool)) {
+	subset := map[string]bool{}
+	var walk func(start int)
+	walk = func(start int) {
+		if len(subset) > 0 {
+			fn(subset)
+		}
+		if len(subset) == k {
+			return
+		}
+		for i := start; i < len(stores); i++ {
+			subset[stores[i]] = true
+			walk(i + 1)
+			delete(subset, stores[i])
+		}
+	}
+	walk(0)
+}
+
+func (s *APIServer) handleOptimizeConfiguration(w http.ResponseWriter, r *http.Request) error {
+	config, err := s.getOwnedConfiguration(r)
+	if err != nil {
+		return err
+	}
+
+	maxStores := defaultMaxStores
+	if v := r.URL.Query().Get("maxStores"); v != "" {
+		maxStores, err = strconv.Atoi(v)
+		if err != nil || maxStores < 1 || maxStores > maxMaxStores {
+			return statusErrorf(http.StatusUnprocessableEntity, "maxStores must be between 1 and %d", maxMaxStores)
+		}
+	}
+	if len(config.Items) == 0 {
+		return statusErrorf(http.StatusUnprocessableEntity, "configuration has no items")
+	}
+
+	ids := make([]int, len(config.Items))
+	for i, item := range config.Items {
+		ids[i] = item.ProductID
+	}
+	equivalents, err := s.store.GetEquivalentProducts(ids)
+	if err != nil {
+		return fmt.Errorf("could not find equivalent listings: %w", err)
+	}
+
+	result := optimizeStores(config, equivalents, maxStores)
+	if result == nil {
+		return statusErrorf(http.StatusUnprocessableEntity, "the parts in this configuration are not available from %d store(s)", maxStores)
+	}
+	return WriteJSON(w, http.StatusOK, result)
+}
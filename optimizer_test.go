@@ -0,0 +1,78 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestOptimizeStores(t *testing.T) {
+	cpu := &Product{ID: 1, Store: "A", Price: 100}
+	gpu := &Product{ID: 2, Store: "B", Price: 300}
+	c := &ComputerConfiguration{
+		Items: []*ConfigurationItem{
+			{ID: 10, ProductID: 1, Quantity: 1, Product: cpu},
+			{ID: 11, ProductID: 2, Quantity: 2, Product: gpu},
+		},
+		TotalPrice: 700,
+	}
+	equivalents := map[int][]*Product{
+		1: {
+			{ID: 3, Store: "B", Price: 130},
+			{ID: 4, Store: "C", Price: 0},
+			{ID: 5, Store: "B", Price: 50, Availability: AvailabilityOutOfStock},
+		},
+		2: {{ID: 6, Store: "A", Price: 310}},
+	}
+
+	tests := []struct {
+		maxStores int
+		total     int64
+		stores    []string
+		picks     []int
+	}{
+		{1, 720, []string{"A"}, []int{1, 6}},
+		{2, 700, []string{"A", "B"}, []int{1, 2}},
+	}
+	for _, tt := range tests {
+		got := optimizeStores(c, equivalents, tt.maxStores)
+		if got == nil {
+			t.Fatalf("maxStores %d: no result", tt.maxStores)
+		}
+		if got.OptimizedTotal != tt.total || !slices.Equal(got.Stores, tt.stores) {
+			t.Errorf("maxStores %d: got total %d from %v, want %d from %v", tt.maxStores, got.OptimizedTotal, got.Stores, tt.total, tt.stores)
+		}
+		for i, a := range got.Assignments {
+			if a.Suggested.ID != tt.picks[i] {
+				t.Errorf("maxStores %d: item %d suggested product %d, want %d", tt.maxStores, i, a.Suggested.ID, tt.picks[i])
+			}
+		}
+		if got.Difference != tt.total-c.TotalPrice {
+			t.Errorf("maxStores %d: difference %d", tt.maxStores, got.Difference)
+		}
+	}
+}
+
+func TestOptimizeStoresImpossible(t *testing.T) {
+	c := &ComputerConfiguration{
+		Items: []*ConfigurationItem{
+			{ProductID: 1, Quantity: 1, Product: &Product{ID: 1, Store: "A", Price: 100}},
+			{ProductID: 2, Quantity: 1, Product: &Product{ID: 2, Store: "B", Price: 100}},
+		},
+	}
+	if got := optimizeStores(c, nil, 1); got != nil {
+		t.Errorf("expected no single-store option, got %+v", got)
+	}
+}
+
+func TestForEachStoreSubset(t *testing.T) {
+	count := 0
+	forEachStoreSubset([]string{"A", "B", "C"}, 2, func(subset map[string]bool) {
+		if len(subset) == 0 || len(subset) > 2 {
+			t.Errorf("unexpected subset %v", subset)
+		}
+		count++
+	})
+	if count != 6 {
+		t.Errorf("got %d subsets, want 6", count)
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	maxPartsListLines       = 100
+	titleMatchCandidates    = 20
+	minTitleMatchSimilarity = 0.6
+)
+
+// PartsListLine is the outcome of resolving one line of a pasted parts list.
+type PartsListLine struct {
+	Line       int      `json:"line"`
+	Text       string   `json:"text"`
+	Quantity   int      `json:"quantity"`
+	Match      string   `json:"match,omitempty"`
+	Similarity float64  `json:"similarity,omitempty"`
+	Product    *Product `json:"product,omitempty"`
+}
+
+var quantityPattern = regexp.MustCompile(`^(?i)(?:(\d{1,2})\s*x\s+(.+)|(.+?)\s+x\s*(\d{1,2}))$`)
+
+// parsePartsListLine splits an optional quantity ("2x ...", "... x2") from
+// the part reference on a line.
+func parsePartsListLine(text string) (string, int) {
+	m := quantityPattern.FindStringSubmatch(text)
+	if m == nil {
+		return text, 1
+	}
+	ref, qty := m[2], m[1]
+	if ref == "" {
+		ref, qty = m[3], m[4]
+	}
+	n, _ := strconv.Atoi(qty)
+	if validateQuantity(n) != nil {
+		return text, 1
+	}
+	return strings.TrimSpace(ref), n
+}
+
+// resolvePart looks a part reference up in the catalog: by store link, then
+// by exact product code, then by the closest title.
+func (s *APIServer) resolvePart(ref string) (*Product, string, float64, error) {
+	if strings.HasPrefix(ref, "http://") || strings.HasPrefix(ref, "https://") {
+		for _, link := range []string{ref, strings.TrimSuffix(ref, "/"), ref + "/"} {
+			p, err := s.store.GetProductByLink(link)
+			if err != nil || p != nil {
+				return p, "link", 1, err
+			}
+		}
+		return nil, "", 0, nil
+	}
+
+	byCode, err := s.store.GetProductsByCode(ref)
+	if err != nil {
+		return nil, "", 0, err
+	}
+	if len(byCode) > 0 {
+		return byCode[0], "code", 1, nil
+	}
+
+	tokens := titleTokens(ref)
+	if len(tokens) == 0 {
+		return nil, "", 0, nil
+	}
+	candidates, err := s.store.SearchProductsByTitleTokens(tokens, titleMatchCandidates)
+	if err != nil {
+		return nil, "", 0, err
+	}
+
+	var best *Product
+	bestScore := 0.0
+	for _, c := range candidates {
+		if score := tokenSimilarity(tokens, titleTokens(c.Title)); score > bestScore {
+			best, bestScore = c, score
+		}
+	}
+	if bestScore < minTitleMatchSimilarity {
+		return nil, "", 0, nil
+	}
+	return best, "title", bestScore, nil
+}
+
+// titleTokens returns the distinct lower case words of s, ignoring
+// punctuation and single characters.
+func titleTokens(s string) []string {
+	seen := map[string]bool{}
+	var tokens []string
+	for _, f := range strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if len([]rune(f)) < 2 || seen[f] {
+			continue
+		}
+		seen[f] = true
+		tokens = append(tokens, f)
+	}
+	return tokens
+}
+
+// tokenSimilarity is the Dice coefficient of two token sets.
+func tokenSimilarity(a, b []string) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	set := map[string]bool{}
+	for _, t := range b {
+		set[t] = true
+	}
+	shared := 0
+	for _, t := range a {
+		if set[t] {
+			shared++
+		}
+	}
+	return 2 * float64(shared) / float64(len(a)+len(b))
+}
+
+func (s *APIServer) handleImportConfiguration(w http.ResponseWriter, r *http.Request) error {
+	userID, err := s.userIDFromRequest(r)
+	if err != nil {
+		return err
+	}
+
+	var req struct {
+		Name string `json:"name"`
+		List string `json:"list"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return err
+	}
+	if req.Name == "" {
+		req.Name = "Imported build"
+	}
+	name, err := validateConfigurationName(req.Name)
+	if err != nil {
+		return err
+	}
+
+	var resolved, unresolved []*PartsListLine
+	for i, text := range strings.Split(req.List, "\n") {
+		text = strings.TrimSpace(text)
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		if len(resolved)+len(unresolved) == maxPartsListLines {
+			return statusErrorf(http.StatusUnprocessableEntity, "parts list may contain at most %d lines", maxPartsListLines)
+		}
+
+		ref, quantity := parsePartsListLine(text)
+		line := &PartsListLine{Line: i + 1, Text: text, Quantity: quantity}
+		line.Product, line.Match, line.Similarity, err = s.resolvePart(ref)
+		if err != nil {
+			return fmt.Errorf("failed to resolve line %d: %w", i+1, err)
+		}
+		if line.Product == nil {
+			unresolved = append(unresolved, line)
+		} else {
+			resolved = append(resolved, line)
+		}
+	}
+
+	response := struct {
+		ConfigID   int              `json:"configID,omitempty"`
+		Resolved   []*PartsListLine `json:"resolved"`
+		Unresolved []*PartsListLine `json:"unresolved"`
+	}{
+		Resolved:   resolved,
+		Unresolved: unresolved,
+	}
+	if len(resolved) == 0 {
+		return WriteJSON(w, http.StatusUnprocessableEntity, response)
+	}
+
+	items := make([]*ConfigurationItem, len(resolved))
+	for i, line := range resolved {
+		items[i] = &ConfigurationItem{ProductID: line.Product.ID, Quantity: line.Quantity}
+	}
+	response.ConfigID, err = s.store.CreateConfigurationWithItems(userID, name, items, "import")
+	if err != nil {
+		return fmt.Errorf("failed to create configuration: %w", err)
+	}
+
+	return WriteJSON(w, http.StatusCreated, response)
+}
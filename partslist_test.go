@@ -0,0 +1,38 @@
+package main
+
+import "testing"
+
+func TestParsePartsListLine(t *testing.T) {
+	tests := []struct {
+		text     string
+		ref      string
+		quantity int
+	}{
+		{"AMD Ryzen 5 7600", "AMD Ryzen 5 7600", 1},
+		{"2x Kingston Fury 16GB", "Kingston Fury 16GB", 2},
+		{"2 x Kingston Fury 16GB", "Kingston Fury 16GB", 2},
+		{"Arctic P12 x3", "Arctic P12", 3},
+		{"Arctic P12 X 3", "Arctic P12", 3},
+		{"20x Arctic P12", "20x Arctic P12", 1},
+		{"0x Arctic P12", "0x Arctic P12", 1},
+		{"Ryzen 9 7950X3D", "Ryzen 9 7950X3D", 1},
+	}
+	for _, tt := range tests {
+		ref, quantity := parsePartsListLine(tt.text)
+		if ref != tt.ref || quantity != tt.quantity {
+			t.Errorf("parsePartsListLine(%q) = %q, %d; want %q, %d", tt.text, ref, quantity, tt.ref, tt.quantity)
+		}
+	}
+}
+
+func TestTokenSimilarity(t *testing.T) {
+	if got := tokenSimilarity([]string{"ryzen", "7600"}, []string{"ryzen", "7600"}); got != 1 {
+		t.Errorf("identical tokens: got %v", got)
+	}
+	if got := tokenSimilarity([]string{"ryzen", "7600"}, []string{"ryzen", "7700"}); got != 0.5 {
+		t.Errorf("half shared: got %v", got)
+	}
+	if got := tokenSimilarity(nil, []string{"ryzen"}); got != 0 {
+		t.Errorf("empty: got %v", got)
+	}
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	passwordResetTTL      = time.Hour
+	passwordResetInterval = time.Minute
+)
+
+func (s *PostgressStore) CreatePasswordResetTokensTable() error {
+	_, err := s.db.Exec(`
+    CREATE TABLE IF NOT EXISTS password_reset_tokens (
+        id SERIAL PRIMARY KEY,
+        user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+        token_hash TEXT NOT NULL UNIQUE,
+        created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+        expires_at TIMESTAMPTZ NOT NULL,
+        used_at TIMESTAMPTZ
+    );
+    CREATE INDEX IF NOT EXISTS password_reset_tokens_user_idx ON password_reset_tokens (user_id, created_at);
+    `)
+	return err
+}
+
+// UpdateUserPassword stores a new password hash and bumps the user's token
+// version, revoking every session issued so far.
+func (s *PostgressStore) UpdateUserPassword(userID int, passwordHash string) (*User, error) {
+	row := s.db.QueryRow(`
+        UPDATE users SET password = $2, token_version = token_version + 1
+        WHERE id = $1
+        RETURNING `+userColumns, userID, passwordHash)
+	return scanIntoUser(row)
+}
+
+// CreatePasswordResetToken stores the hash of a new reset token. It returns
+// false without storing anything when the user was sent a token within the
+// interval.
+func (s *PostgressStore) CreatePasswordResetToken(userID int, tokenHash string, ttl, interval time.Duration) (bool, error) {
+	res, err := s.db.Exec(`
+        INSERT INTO password_reset_tokens (user_id, token_hash, expires_at)
+        SELECT $1, $2, NOW() + make_interval(secs => $3)
+        WHERE NOT EXISTS (
+            SELECT 1 FROM password_reset_tokens
+            WHERE user_id = $1 AND created_at > NOW() - make_interval(secs => $4)
+        )
+    `, userID, tokenHash, ttl.Seconds(), interval.Seconds())
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// GetPasswordResetTokenUserID returns the user an unused, unexpired reset
+// token belongs to, or 0 when there is no such token.
+func (s *PostgressStore) GetPasswordResetTokenUserID(tokenHash string) (int, error) {
+	var userID int
+	err := s.db.QueryRow(`
+        SELECT user_id FROM password_reset_tokens
+        WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+    `, tokenHash).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return userID, err
+}
+
+// ResetPassword consumes a reset token and sets the password of its user.
+// Every other outstanding token of that user is invalidated as well. It
+// returns sql.ErrNoRows when the token is unknown, used or expired.
+func (s *PostgressStore) ResetPassword(tokenHash, passwordHash string) (*User, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var userID int
+	err = tx.QueryRow(`
+        UPDATE password_reset_tokens SET used_at = NOW()
+        WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+        RETURNING user_id
+    `, tokenHash).Scan(&userID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(`
+        UPDATE password_reset_tokens SET used_at = NOW()
+        WHERE user_id = $1 AND used_at IS NULL
+    `, userID); err != nil {
+		return nil, err
+	}
+
+	// Following the emailed link proves ownership of the address too.
+	user, err := scanIntoUser(tx.QueryRow(`
+        UPDATE users SET password = $2, token_version = token_version + 1,
+            verified_at = COALESCE(verified_at, NOW())
+        WHERE id = $1
+        RETURNING `+userColumns, userID, passwordHash))
+	if err != nil {
+		return nil, err
+	}
+	return user, tx.Commit()
+}
+
+func newPasswordResetToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashResetToken is what gets stored, so a leaked table cannot be used to
+// reset passwords.
+func hashResetToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *APIServer) handleForgotPassword(w http.ResponseWriter, r *http.Request) error {
+	var req struct {
+		Email string `json:"email"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return err
+	}
+
+	// The response is the same whether or not the account exists, so the
+	// endpoint cannot be used to find out who is registered.
+	if err := s.sendPasswordResetEmail(strings.TrimSpace(req.Email)); err != nil {
+		log.Printf("could not send password reset email: %v", err)
+	}
+	return WriteJSON(w, http.StatusOK, map[string]string{
+		"message": "if an account with this email exists, a password reset link has been sent",
+	})
+}
+
+func (s *APIServer) sendPasswordResetEmail(email string) error {
+	if email == "" {
+		return nil
+	}
+	user, err := s.store.GetUserByEmail(email)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	token, err := newPasswordResetToken()
+	if err != nil {
+		return err
+	}
+	created, err := s.store.CreatePasswordResetToken(user.ID, hashResetToken(token), passwordResetTTL, passwordResetInterval)
+	if err != nil || !created {
+		return err
+	}
+
+	link := appBaseURL() + "/reset-password?token=" + url.QueryEscape(token)
+	return s.notifier.Notify(&Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Someone asked to reset the password of your pcpartsmk account.\n\n"+
+			"To choose a new password, open this link within an hour:\n\n%s\n\n"+
+			"If it wasn't you, you can ignore this email; your password has not been changed.\n", link),
+	})
+}
+
+func (s *APIServer) handleResetPassword(w http.ResponseWriter, r *http.Request) error {
+	var req struct {
+		Token       string `json:"token"`
+		NewPassword string `json:"newPassword"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return err
+	}
+
+	tokenHash := hashResetToken(req.Token)
+	userID, err := s.store.GetPasswordResetTokenUserID(tokenHash)
+	if err != nil {
+		return fmt.Errorf("could not check reset token: %w", err)
+	}
+	if userID == 0 {
+		return fmt.Errorf("invalid or expired reset token")
+	}
+	user, err := s.store.GetUserByID(userID)
+	if err != nil {
+		return fmt.Errorf("could not get user: %w", err)
+	}
+	if user == nil {
+		return fmt.Errorf("invalid or expired reset token")
+	}
+
+	passwordHash, err := s.passwordPolicy.Hash(req.NewPassword, user.Email)
+	if err != nil {
+		return err
+	}
+	_, err = s.store.ResetPassword(tokenHash, passwordHash)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("invalid or expired reset token")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to reset password: %w", err)
+	}
+
+	return WriteJSON(w, http.StatusOK, map[string]string{"message": "password has been reset, please log in"})
+}
+
+func (s *APIServer) handleChangePassword(w http.ResponseWriter, r *http.Request) error {
+	user, err := s.authenticate(r)
+	if err != nil {
+		return err
+	}
+
+	var req struct {
+		CurrentPassword string `json:"currentPassword"`
+		NewPassword     string `json:"newPassword"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return err
+	}
+
+	// Checking the current password is as good as a login attempt, so it
+	// is throttled the same way.
+	key, ip := loginKey(user.Email), s.loginLimiter.clientIP(r)
+	if wait := s.loginLimiter.Reserve(key, ip); wait > 0 {
+		return tooManyLoginAttempts(w, wait)
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.CurrentPassword)); err != nil {
+		s.recordFailedLogin(user, key, ip)
+		return statusErrorf(http.StatusForbidden, "current password is incorrect")
+	}
+	s.loginLimiter.Succeed(key, ip)
+	passwordHash, err := s.passwordPolicy.Hash(req.NewPassword, user.Email)
+	if err != nil {
+		return err
+	}
+	user, err = s.store.UpdateUserPassword(user.ID, passwordHash)
+	if err != nil {
+		return fmt.Errorf("failed to change password: %w", err)
+	}
+
+	// Every other session has been revoked; hand this client a fresh token
+	// so it stays logged in.
+	token, err := GenerateJWT(user)
+	if err != nil {
+		return fmt.Errorf("failed to generate token: %w", err)
+	}
+	return WriteJSON(w, http.StatusOK, map[string]string{"token": token})
+}
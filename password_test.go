@@ -0,0 +1,85 @@
+package main
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestHashResetToken(t *testing.T) {
+	token, err := newPasswordResetToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := newPasswordResetToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token == other {
+		t.Fatal("reset tokens repeat")
+	}
+
+	hash := hashResetToken(token)
+	if len(hash) != 64 || hash == token {
+		t.Errorf("hashResetToken = %q", hash)
+	}
+	if hashResetToken(token) != hash {
+		t.Error("hashResetToken is not deterministic")
+	}
+	if hashResetToken(other) == hash {
+		t.Error("different tokens hash the same")
+	}
+}
+
+func TestCreatePasswordResetTokenThrottle(t *testing.T) {
+	s := testStore(t)
+	user := testUser(t, s)
+
+	created, err := s.CreatePasswordResetToken(user.ID, hashResetToken("first"+user.Email), time.Hour, time.Minute)
+	if err != nil || !created {
+		t.Fatalf("first token: created = %v, err = %v", created, err)
+	}
+	created, err = s.CreatePasswordResetToken(user.ID, hashResetToken("second"+user.Email), time.Hour, time.Minute)
+	if err != nil || created {
+		t.Fatalf("token within the interval: created = %v, err = %v", created, err)
+	}
+}
+
+func TestResetPasswordIsSingleUse(t *testing.T) {
+	s := testStore(t)
+	user := testUser(t, s)
+	tokenHash := hashResetToken("reset" + user.Email)
+	if _, err := s.CreatePasswordResetToken(user.ID, tokenHash, time.Hour, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if id, err := s.GetPasswordResetTokenUserID(tokenHash); err != nil || id != user.ID {
+		t.Fatalf("GetPasswordResetTokenUserID = %d, %v, want %d", id, err, user.ID)
+	}
+	updated, err := s.ResetPassword(tokenHash, "new hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Password != "new hash" || updated.TokenVersion != user.TokenVersion+1 || updated.VerifiedAt == nil {
+		t.Errorf("user after reset = %+v", updated)
+	}
+
+	if _, err := s.ResetPassword(tokenHash, "another hash"); err != sql.ErrNoRows {
+		t.Errorf("second reset err = %v, want sql.ErrNoRows", err)
+	}
+	if id, err := s.GetPasswordResetTokenUserID(tokenHash); err != nil || id != 0 {
+		t.Errorf("used token still resolves to user %d, err = %v", id, err)
+	}
+}
+
+func TestResetPasswordRejectsExpiredToken(t *testing.T) {
+	s := testStore(t)
+	user := testUser(t, s)
+	tokenHash := hashResetToken("expired" + user.Email)
+	if _, err := s.CreatePasswordResetToken(user.ID, tokenHash, -time.Minute, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.ResetPassword(tokenHash, "new hash"); err != sql.ErrNoRows {
+		t.Errorf("expired token err = %v, want sql.ErrNoRows", err)
+	}
+}
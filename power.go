@@ -0,0 +1,200 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	psuTargetLoad      = 0.65
+	psuStep            = 50
+	minRecommendedPSU  = 300
+	psuCandidatesLimit = 500
+	psuSuggestions     = 5
+)
+
+// defaultPower is the typical draw in watts of a component whose rating is
+// not stated in its listing.
+var defaultPower = map[string]int{
+	"cpu":         105,
+	"gpu":         200,
+	"motherboard": 50,
+	"ram":         5,
+	"storage":     7,
+	"cooler":      5,
+	"fan":         3,
+}
+
+// peakFactor scales nominal power to short turbo and transient spikes.
+var peakFactor = map[string]float64{
+	"cpu": 1.4,
+	"gpu": 1.5,
+}
+
+// gpuBoardPower lists the reference board power of common graphics chips,
+// checked in order so that "4070 ti" wins over "4070".
+var gpuBoardPower = []struct {
+	pattern *regexp.Regexp
+	watts   int
+}{
+	{regexp.MustCompile(`(?i)\b5090\b`), 575},
+	{regexp.MustCompile(`(?i)\b5080\b`), 360},
+	{regexp.MustCompile(`(?i)\b5070\s*ti\b`), 300},
+	{regexp.MustCompile(`(?i)\b5070\b`), 250},
+	{regexp.MustCompile(`(?i)\b5060\s*ti\b`), 180},
+	{regexp.MustCompile(`(?i)\b5060\b`), 145},
+	{regexp.MustCompile(`(?i)\b4090\b`), 450},
+	{regexp.MustCompile(`(?i)\b4080\b`), 320},
+	{regexp.MustCompile(`(?i)\b4070\s*ti\b`), 285},
+	{regexp.MustCompile(`(?i)\b4070\b`), 200},
+	{regexp.MustCompile(`(?i)\b4060\s*ti\b`), 160},
+	{regexp.MustCompile(`(?i)\b4060\b`), 115},
+	{regexp.MustCompile(`(?i)\b3090\b`), 350},
+	{regexp.MustCompile(`(?i)\b3080\b`), 320},
+	{regexp.MustCompile(`(?i)\b3070\b`), 220},
+	{regexp.MustCompile(`(?i)\b3060\b`), 170},
+	{regexp.MustCompile(`(?i)\b3050\b`), 130},
+	{regexp.MustCompile(`(?i)\b9070\s*xt\b`), 304},
+	{regexp.MustCompile(`(?i)\b9070\b`), 220},
+	{regexp.MustCompile(`(?i)\b7900\s*xtx\b`), 355},
+	{regexp.MustCompile(`(?i)\b7900\s*xt\b`), 315},
+	{regexp.MustCompile(`(?i)\b7800\s*xt\b`), 263},
+	{regexp.MustCompile(`(?i)\b7700\s*xt\b`), 245},
+	{regexp.MustCompile(`(?i)\b7600\b`), 165},
+	{regexp.MustCompile(`(?i)\b6600\b`), 132},
+}
+
+// ComponentPower is the estimated draw of one configuration item.
+type ComponentPower struct {
+	ItemID   int    `json:"itemID"`
+	Title    string `json:"title"`
+	Kind     string `json:"kind"`
+	Quantity int    `json:"quantity"`
+	Watts    int    `json:"watts"`
+	Source   string `json:"source"`
+}
+
+type PowerEstimate struct {
+	LoadWatts            int               `json:"loadWatts"`
+	PeakWatts            int               `json:"peakWatts"`
+	RecommendedPSUWatts  int               `json:"recommendedPsuWatts"`
+	CurrentPSUWatts      int               `json:"currentPsuWatts,omitempty"`
+	CurrentPSUSufficient *bool             `json:"currentPsuSufficient,omitempty"`
+	Components           []*ComponentPower `json:"components"`
+	SuggestedPSUs        []*Product        `json:"suggestedPsus"`
+}
+
+// componentPower estimates the draw of a single unit of p, reporting whether
+// the figure came from the listing or from a typical value.
+func componentPower(kind string, p *Product) (int, string) {
+	switch kind {
+	case "psu", "case", "monitor", "peripheral", "other":
+		return 0, "none"
+	}
+	if watts, ok := ratedPower(p); ok {
+		return watts, "listing"
+	}
+	if kind == "gpu" {
+		for _, g := range gpuBoardPower {
+			if g.pattern.MatchString(p.Title) {
+				return g.watts, "model"
+			}
+		}
+	}
+	if kind == "storage" {
+		title := strings.ToLower(p.Title)
+		if strings.Contains(title, "hdd") || strings.Contains(title, "rpm") {
+			return 9, "estimate"
+		}
+	}
+	return defaultPower[kind], "estimate"
+}
+
+// estimatePower sums the expected sustained and peak draw of c and derives
+// a PSU size that keeps sustained load around psuTargetLoad.
+func estimatePower(c *ComputerConfiguration) *PowerEstimate {
+	estimate := &PowerEstimate{Components: []*ComponentPower{}, SuggestedPSUs: []*Product{}}
+
+	load, peak := 0.0, 0.0
+	for _, item := range c.Items {
+		kind := itemKind(item)
+		if kind == "psu" {
+			estimate.CurrentPSUWatts += psuWattage(item.Product) * item.Quantity
+		}
+
+		watts, source := componentPower(kind, item.Product)
+		estimate.Components = append(estimate.Components, &ComponentPower{
+			ItemID:   item.ID,
+			Title:    item.Product.Title,
+			Kind:     kind,
+			Quantity: item.Quantity,
+			Watts:    watts,
+			Source:   source,
+		})
+
+		factor, ok := peakFactor[kind]
+		if !ok {
+			factor = 1
+		}
+		load += float64(watts * item.Quantity)
+		peak += float64(watts*item.Quantity) * factor
+	}
+
+	estimate.LoadWatts = int(math.Round(load))
+	estimate.PeakWatts = int(math.Round(peak))
+	estimate.RecommendedPSUWatts = recommendedPSU(load, peak)
+	if estimate.CurrentPSUWatts > 0 {
+		sufficient := estimate.CurrentPSUWatts >= estimate.RecommendedPSUWatts
+		estimate.CurrentPSUSufficient = &sufficient
+	}
+	return estimate
+}
+
+func recommendedPSU(load, peak float64) int {
+	watts := math.Max(load/psuTargetLoad, peak)
+	rounded := int(math.Ceil(watts/psuStep)) * psuStep
+	if rounded < minRecommendedPSU {
+		return minRecommendedPSU
+	}
+	return rounded
+}
+
+// suggestPSUs returns the cheapest power supplies in the catalog rated for at
+// least the given wattage.
+func (s *APIServer) suggestPSUs(watts int, limit int) ([]*Product, error) {
+	candidates, err := s.store.SearchProductsByKeywords(componentKeywordsFor("psu"), psuCandidatesLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	psus := []*Product{}
+	for _, p := range candidates {
+		if componentKind(p) == "psu" && psuWattage(p) >= watts && p.Price > 0 {
+			psus = append(psus, p)
+		}
+	}
+	sort.SliceStable(psus, func(i, j int) bool { return psus[i].Price < psus[j].Price })
+	if len(psus) > limit {
+		psus = psus[:limit]
+	}
+	return psus, nil
+}
+
+func (s *APIServer) handleGetConfigurationPower(w http.ResponseWriter, r *http.Request) error {
+	config, err := s.getOwnedConfiguration(r)
+	if err != nil {
+		return err
+	}
+
+	estimate := estimatePower(config)
+	estimate.SuggestedPSUs, err = s.suggestPSUs(estimate.RecommendedPSUWatts, psuSuggestions)
+	if err != nil {
+		return fmt.Errorf("could not find power supplies: %w", err)
+	}
+
+	return WriteJSON(w, http.StatusOK, estimate)
+}
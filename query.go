@@ -0,0 +1,232 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+	// maxPage keeps page offsets far from overflowing; deeper listings
+	// should follow the after cursor instead.
+	maxPage = 10000
+)
+
+// ProductQuery describes a product listing request. Multi-value filters accept
+// both repeated parameters (?store=a&store=b) and comma separated values.
+type ProductQuery struct {
+	Categories           []string
+	CategorySlugs        []string
+	Manufacturers        []string
+	Stores               []string
+	ExcludeCategories    []string
+	ExcludeManufacturers []string
+	ExcludeStores        []string
+	MinPrice             *int64
+	MaxPrice             *int64
+	MinWarranty          *int64
+	Title                string
+	Sort                 string
+	Page                 int
+	PageSize             int
+	After                string
+	SkipCount            bool
+	InStock              bool
+}
+
+// ParseProductQuery builds a ProductQuery from URL parameters. Invalid values
+// are reported as 422 errors instead of being silently ignored.
+func ParseProductQuery(values url.Values) (*ProductQuery, error) {
+	q := &ProductQuery{
+		Categories:           queryList(values, "category"),
+		CategorySlugs:        queryList(values, "categorySlug"),
+		Manufacturers:        queryList(values, "manufacturer"),
+		Stores:               queryList(values, "store"),
+		ExcludeCategories:    queryList(values, "excludeCategory"),
+		ExcludeManufacturers: queryList(values, "excludeManufacturer"),
+		ExcludeStores:        queryList(values, "excludeStore"),
+		Title:                strings.TrimSpace(values.Get("title")),
+		Sort:                 values.Get("sort"),
+		Page:                 1,
+		PageSize:             defaultPageSize,
+		After:                values.Get("after"),
+	}
+
+	var err error
+	if q.MinPrice, err = queryInt64(values, "minPrice"); err != nil {
+		return nil, err
+	}
+	if q.MaxPrice, err = queryInt64(values, "maxPrice"); err != nil {
+		return nil, err
+	}
+	if q.MinWarranty, err = queryInt64(values, "minWarranty"); err != nil {
+		return nil, err
+	}
+	if q.MinPrice != nil && q.MaxPrice != nil && *q.MinPrice > *q.MaxPrice {
+		return nil, statusErrorf(http.StatusUnprocessableEntity, "minPrice must not exceed maxPrice")
+	}
+
+	if v := values.Get("page"); v != "" {
+		page, err := strconv.Atoi(v)
+		if err != nil || page < 1 || page > maxPage {
+			return nil, statusErrorf(http.StatusUnprocessableEntity, "page must be between 1 and %d", maxPage)
+		}
+		q.Page = page
+	}
+	if v := values.Get("pageSize"); v != "" {
+		pageSize, err := strconv.Atoi(v)
+		if err != nil || pageSize < 1 || pageSize > maxPageSize {
+			return nil, statusErrorf(http.StatusUnprocessableEntity, "pageSize must be between 1 and %d", maxPageSize)
+		}
+		q.PageSize = pageSize
+	}
+
+	if _, ok := productSorts[q.Sort]; !ok {
+		return nil, statusErrorf(http.StatusUnprocessableEntity, "unknown sort %q", q.Sort)
+	}
+	if q.After != "" {
+		if _, err := decodeProductCursor(q.After, q.Sort); err != nil {
+			return nil, statusErrorf(http.StatusUnprocessableEntity, "%v", err)
+		}
+	}
+
+	if v := values.Get("skipCount"); v != "" {
+		skip, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, statusErrorf(http.StatusUnprocessableEntity, "invalid skipCount %q", v)
+		}
+		q.SkipCount = skip
+	}
+
+	if v := values.Get("inStock"); v != "" {
+		inStock, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, statusErrorf(http.StatusUnprocessableEntity, "invalid inStock %q", v)
+		}
+		q.InStock = inStock
+	}
+
+	return q, nil
+}
+
+func queryList(values url.Values, key string) []string {
+	var list []string
+	for _, raw := range values[key] {
+		for _, v := range strings.Split(raw, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				list = append(list, v)
+			}
+		}
+	}
+	return list
+}
+
+func queryInt64(values url.Values, key string) (*int64, error) {
+	v := values.Get(key)
+	if v == "" {
+		return nil, nil
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 {
+		return nil, statusErrorf(http.StatusUnprocessableEntity, "invalid %s %q", key, v)
+	}
+	return &n, nil
+}
+
+// productFilter accumulates WHERE conditions and their positional arguments.
+type productFilter struct {
+	query string
+	args  []interface{}
+}
+
+func (f *productFilter) nextArg(v interface{}) string {
+	f.args = append(f.args, v)
+	return fmt.Sprintf("$%d", len(f.args))
+}
+
+func (f *productFilter) add(format string, v interface{}) {
+	f.query += " AND " + fmt.Sprintf(format, f.nextArg(v))
+}
+
+func (f *productFilter) in(column string, values []string, negate bool) {
+	if len(values) == 0 {
+		return
+	}
+	placeholders := make([]string, len(values))
+	for i, v := range values {
+		placeholders[i] = f.nextArg(v)
+	}
+	op := "IN"
+	if negate {
+		op = "NOT IN"
+	}
+	f.query += fmt.Sprintf(" AND %s %s (%s)", column, op, strings.Join(placeholders, ","))
+}
+
+// brands matches products by manufacturer, either as spelled by the store
+// or by any name of its brand, so "Asus" also finds "ASUSTeK".
+func (f *productFilter) brands(values []string, negate bool) {
+	if len(values) == 0 {
+		return
+	}
+	normalized := make([]string, len(values))
+	for i, v := range values {
+		normalized[i] = normalizeBrandAlias(v)
+	}
+	raw, names := f.nextArg(pq.Array(values)), f.nextArg(pq.Array(normalized))
+
+	cond := fmt.Sprintf(`(COALESCE(manufacturer = ANY(%s), false) OR COALESCE(brand_id IN (
+			SELECT brand_id FROM brand_aliases WHERE alias = ANY(%s)
+			UNION SELECT id FROM brands WHERE slug = ANY(%s)
+		), false))`, raw, names, names)
+	if negate {
+		cond = "NOT " + cond
+	}
+	f.query += " AND " + cond
+}
+
+// where translates the filters of q, excluding pagination, into SQL.
+func (q *ProductQuery) where() *productFilter {
+	f := &productFilter{query: " WHERE 1=1"}
+
+	f.in("category", q.Categories, false)
+	if len(q.CategorySlugs) > 0 {
+		// A category matches its whole subtree, so "storage" includes NVMe.
+		f.query += fmt.Sprintf(` AND category_id IN (
+			WITH RECURSIVE tree AS (
+				SELECT id FROM categories WHERE slug = ANY(%s)
+				UNION SELECT c.id FROM categories c JOIN tree ON c.parent_id = tree.id
+			)
+			SELECT id FROM tree
+		)`, f.nextArg(pq.Array(q.CategorySlugs)))
+	}
+	f.brands(q.Manufacturers, false)
+	f.in("store", q.Stores, false)
+	f.in("category", q.ExcludeCategories, true)
+	f.brands(q.ExcludeManufacturers, true)
+	f.in("store", q.ExcludeStores, true)
+	if q.InStock {
+		f.in("availability", purchasableAvailabilities, false)
+	}
+
+	if q.MinPrice != nil {
+		f.add("price >= %s", *q.MinPrice)
+	}
+	if q.MaxPrice != nil {
+		f.add("price <= %s", *q.MaxPrice)
+	}
+	if q.MinWarranty != nil {
+		f.add("warranty >= %s", *q.MinWarranty)
+	}
+	if q.Title != "" {
+		f.add("title ILIKE %s", "%"+q.Title+"%")
+	}
+
+	return f
+}
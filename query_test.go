@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"testing"
+)
+
+func TestParseProductQuery(t *testing.T) {
+	q, err := ParseProductQuery(url.Values{
+		"store":     {"Anhoch, Setec", "Neptun"},
+		"minPrice":  {"1000"},
+		"maxPrice":  {"5000"},
+		"title":     {"  ryzen "},
+		"sort":      {"price_desc"},
+		"page":      {"3"},
+		"pageSize":  {"50"},
+		"skipCount": {"true"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(q.Stores, []string{"Anhoch", "Setec", "Neptun"}) {
+		t.Errorf("stores = %v", q.Stores)
+	}
+	if q.MinPrice == nil || *q.MinPrice != 1000 || q.MaxPrice == nil || *q.MaxPrice != 5000 {
+		t.Errorf("price range = %v..%v", q.MinPrice, q.MaxPrice)
+	}
+	if q.Title != "ryzen" || q.Sort != "price_desc" || q.Page != 3 || q.PageSize != 50 || !q.SkipCount {
+		t.Errorf("got %+v", q)
+	}
+}
+
+func TestParseProductQueryDefaults(t *testing.T) {
+	q, err := ParseProductQuery(url.Values{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.Page != 1 || q.PageSize != defaultPageSize || q.MinPrice != nil || q.SkipCount {
+		t.Errorf("got %+v", q)
+	}
+}
+
+func TestParseProductQueryInvalid(t *testing.T) {
+	tests := map[string]url.Values{
+		"negative price":   {"minPrice": {"-1"}},
+		"price not number": {"maxPrice": {"cheap"}},
+		"inverted range":   {"minPrice": {"500"}, "maxPrice": {"100"}},
+		"page zero":        {"page": {"0"}},
+		"page too deep":    {"page": {"9223372036854775807"}},
+		"page past limit":  {"page": {"10001"}},
+		"page size":        {"pageSize": {"101"}},
+		"unknown sort":     {"sort": {"name"}},
+		"bad cursor":       {"after": {"???"}},
+		"bad skipCount":    {"skipCount": {"maybe"}},
+	}
+	for name, values := range tests {
+		_, err := ParseProductQuery(values)
+		var statusErr *StatusError
+		if !errors.As(err, &statusErr) || statusErr.Status != http.StatusUnprocessableEntity {
+			t.Errorf("%s: got %v, want a 422 error", name, err)
+		}
+	}
+}
+
+func TestProductQueryWhere(t *testing.T) {
+	minWarranty := int64(24)
+	q := &ProductQuery{
+		Categories:    []string{"CPU"},
+		ExcludeStores: []string{"Neptun", "Setec"},
+		MinWarranty:   &minWarranty,
+		Title:         "ryzen",
+	}
+	f := q.where()
+	want := " WHERE 1=1 AND category IN ($1) AND store NOT IN ($2,$3) AND warranty >= $4 AND title ILIKE $5"
+	if f.query != want {
+		t.Errorf("query = %q, want %q", f.query, want)
+	}
+	if len(f.args) != 5 || f.args[4] != "%ryzen%" {
+		t.Errorf("args = %v", f.args)
+	}
+}
@@ -2,51 +2,160 @@ package main
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"strconv"
 	"strings"
+	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 type Storage interface {
 	CreateProduct(*Product) error
+	UpsertProduct(*Product) (*PriceChange, error)
 	GetProducts() ([]*Product, error)
-	GetFilteredProducts(category, manufacturer, store, minPrice, maxPrice, title, pageStr, pageSizeStr string) ([]*Product, int, error)
-	GetUniqueManufacturers() ([]string, error)
-	GetManufacturersByCategory(category string) ([]string, error)
-	GetUniqueStores() ([]string, error)
+	GetFilteredProducts(q *ProductQuery) ([]*Product, int, string, error)
 	GetProductByID(id int) (*Product, error)
 	CreateUser(*User) error
 	GetUserByEmail(email string) (*User, error)
+	GetUserByID(id int) (*User, error)
+	MarkUserVerified(id int) error
+	ClaimVerificationEmail(userID int, interval time.Duration) (bool, error)
+	UpdateUserPassword(userID int, passwordHash string) (*User, error)
+	CreatePasswordResetToken(userID int, tokenHash string, ttl, interval time.Duration) (bool, error)
+	GetPasswordResetTokenUserID(tokenHash string) (int, error)
+	ResetPassword(tokenHash, passwordHash string) (*User, error)
+	RecordAuditEvent(e *AuditEvent) error
+	SetUserRole(userID int, role string) error
+	GetAdminProduct(id int) (*AdminProduct, error)
+	CreateCuratedProduct(p *AdminProduct) error
+	UpdateProduct(p *AdminProduct) (*PriceChange, error)
+	BulkUpdateProducts(q *ProductQuery, patch *ProductPatch) ([]int, error)
+	CountProducts(q *ProductQuery) (int, error)
+	DeleteProduct(id int) error
+	GetCategories() ([]*Category, error)
+	CreateCategory(c *Category, parentSlug string) error
+	GetCategoryMappings() ([]*CategoryMapping, error)
+	SetCategoryMapping(m *CategoryMapping) error
+	DeleteCategoryMapping(id int) error
+	GetUnmappedCategories() ([]*UnmappedCategory, error)
+	ApplyCategoryMappings(productIDs []int) ([]*Product, error)
+	SetProductCategories(slugs map[int]string) error
+	GetBrands(q *ProductQuery) ([]*Brand, error)
+	GetBrandByID(id int) (*Brand, error)
+	UpdateBrand(b *Brand) error
+	SetBrandAlias(brandID int, alias string) error
+	MergeBrands(into, from int) error
+	GetUnknownManufacturers(productIDs []int) ([]string, error)
+	CreateBrand(name string) (*Brand, error)
+	ApplyBrandAliases(productIDs []int) error
+	GetStores() ([]*Store, error)
+	GetAdminStore(idOrSlug string) (*AdminStore, error)
+	UpdateStore(st *AdminStore) error
+	RecordStoreImport(name string) error
+	MarkUnseenListingsOutOfStock(name string) error
+	GetStoreCategoryCounts(name string) ([]*StoreCategoryCount, error)
+	GetStorePriceIndex(name string) (float64, int, error)
+	GetStoreFreshness(name string) (*time.Time, int, error)
+	RecordStockSnapshot() error
+	GetStockHistory(productID int) ([]*StockSnapshot, error)
 	CreateConfiguration(userID int, name string) (int, error)
-	AddProductToConfiguration(configID, productID int) error
-	RemoveProductFromConfiguration(configID, productID int) error
+	GetConfigurationByID(id int) (*ComputerConfiguration, error)
+	RenameConfiguration(id int, name string) error
+	DeleteConfiguration(id int) error
+	DuplicateConfiguration(id, userID int, name, action string) (int, error)
+	SetConfigurationVisibility(id int, visibility, slug string) (string, error)
+	GetConfigurationBySlug(slug string) (*ComputerConfiguration, error)
+	GetPublicConfigurations(limit int) ([]*ComputerConfiguration, error)
+	AddProductToConfiguration(configID, productID, quantity int, slot string, actorID int, action string) error
+	CreateConfigurationWithItems(userID int, name string, items []*ConfigurationItem, action string) (int, error)
+	SetConfigurationItemQuantity(configID, itemID, quantity, actorID int) error
+	RemoveConfigurationItem(configID, itemID, actorID int) error
+	RemoveProductFromConfiguration(configID, productID, actorID int) error
 	GetProductsByConfigurationID(configID int) ([]*Product, error)
+	GetConfigurationItems(configID int) ([]*ConfigurationItem, error)
+	GetConfigurationRevisions(configID int) ([]*ConfigurationRevision, error)
+	GetConfigurationRevision(configID, revisionID int) (*ConfigurationRevision, error)
+	RestoreConfigurationRevision(configID, revisionID, actorID int) error
+	CreatePriceAlert(*PriceAlert) error
+	GetPriceAlertsByUserID(userID int) ([]*PriceAlert, error)
+	DeletePriceAlert(id, userID int) error
+	GetActivePriceAlerts(productIDs []int) ([]*PriceAlert, error)
+	MarkPriceAlertTriggered(id int, price int64) error
+	MarkPriceAlertFailed(id int) error
+	CreateWishlist(userID int, name string) (*Wishlist, error)
+	GetDefaultWishlist(userID int) (*Wishlist, error)
+	GetWishlistByID(id int) (*Wishlist, error)
+	GetWishlistsByUserID(userID int) ([]*Wishlist, error)
+	RenameWishlist(id int, name string) error
+	DeleteWishlist(id int) error
+	AddProductToWishlist(wishlistID, productID int) error
+	RemoveProductFromWishlist(wishlistID, productID int) error
 	GetConfigurationsByUserID(userID int) ([]*ComputerConfiguration, error)
+	GetEquivalentProducts(productIDs []int) (map[int][]*Product, error)
+	RefreshConfigurationTotals() error
 	GetRandomProducts(limit int) ([]*Product, error)
+	GetProductByLink(link string) (*Product, error)
+	GetProductsByCode(code string) ([]*Product, error)
+	SearchProductsByTitleTokens(tokens []string, limit int) ([]*Product, error)
+	SearchProductsByKeywords(keywords []string, limit int) ([]*Product, error)
+	GetComponentCandidates(kind string, stores []string, maxPrice int64, limit int) ([]*Product, error)
 }
 
 type PostgressStore struct {
 	db *sql.DB
 }
 
+// isUniqueViolation reports whether err is a Postgres unique constraint
+// violation.
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// isForeignKeyViolation reports whether err is a Postgres foreign key
+// violation, such as deleting a row that is still referenced.
+func isForeignKeyViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23503"
+}
+
 func (s *PostgressStore) CreateUser(user *User) error {
-	_, err := s.db.Exec(`
+	return s.db.QueryRow(`
 		INSERT INTO users (email, password) VALUES ($1, $2)
-	`, user.Email, user.Password)
-	return err
+		RETURNING id, role
+	`, user.Email, user.Password).Scan(&user.ID, &user.Role)
 }
 
-func (s *PostgressStore) GetUserByEmail(email string) (*User, error) {
-	row := s.db.QueryRow("SELECT id, email, password FROM users WHERE email = $1", email)
+const userColumns = "id, email, password, verified_at, token_version, role"
+
+func scanIntoUser(row rowScanner) (*User, error) {
 	user := new(User)
-	err := row.Scan(&user.ID, &user.Email, &user.Password)
-	if err != nil {
+	var verifiedAt sql.NullTime
+	if err := row.Scan(&user.ID, &user.Email, &user.Password, &verifiedAt, &user.TokenVersion, &user.Role); err != nil {
 		return nil, err
 	}
+	if verifiedAt.Valid {
+		user.VerifiedAt = &verifiedAt.Time
+	}
 	return user, nil
 }
 
+func (s *PostgressStore) GetUserByEmail(email string) (*User, error) {
+	row := s.db.QueryRow("SELECT "+userColumns+" FROM users WHERE LOWER(email) = LOWER($1)", email)
+	return scanIntoUser(row)
+}
+
+func (s *PostgressStore) GetUserByID(id int) (*User, error) {
+	row := s.db.QueryRow("SELECT "+userColumns+" FROM users WHERE id = $1", id)
+	user, err := scanIntoUser(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return user, err
+}
+
 func NewPostgressStore() (*PostgressStore, error) {
 	connStr := "host=db user=postgres dbname=pcshops password=pcshops sslmode=disable"
 	db, err := sql.Open("postgres", connStr)
@@ -84,21 +193,146 @@ func (s *PostgressStore) createProductsTable() error {
 		category TEXT,
 		description TEXT,
 		image TEXT,
-		store TEXT
-	)`
+		store TEXT,
+		last_seen_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	);
+	ALTER TABLE products ADD COLUMN IF NOT EXISTS last_seen_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
+	ALTER TABLE products ADD COLUMN IF NOT EXISTS overridden_fields TEXT[] NOT NULL DEFAULT '{}';
+	ALTER TABLE products ADD COLUMN IF NOT EXISTS availability TEXT NOT NULL DEFAULT 'unknown'
+		CHECK (availability IN ('in_stock', 'limited', 'on_order', 'out_of_stock', 'unknown'));
+	ALTER TABLE products ADD COLUMN IF NOT EXISTS delivery_estimate TEXT NOT NULL DEFAULT ''`
 
 	_, err := s.db.Exec(query)
 	return err
 }
 
+// CreateProductListingIndex makes a store's link identify one listing, so
+// imports can upsert on it. Databases from before imports upserted hold a
+// copy of each listing per startup; the copies are first merged into the
+// oldest one, taking their configuration items, alerts and wishlist entries
+// along. It runs after the tables that reference products exist.
+func (s *PostgressStore) CreateProductListingIndex() error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, query := range []string{
+		`CREATE TEMP TABLE listing_copies ON COMMIT DROP AS
+		 SELECT id, keep_id FROM (
+		     SELECT id, MIN(id) OVER w AS keep_id, COUNT(*) OVER w AS copies
+		     FROM products
+		     WHERE store IS NOT NULL AND link <> ''
+		     WINDOW w AS (PARTITION BY store, link)
+		 ) l
+		 WHERE copies > 1`,
+		// A configuration may hold several copies as unslotted items; they
+		// become one item with the quantities added up.
+		`CREATE TEMP TABLE merged_items ON COMMIT DROP AS
+		 SELECT MIN(i.id) AS item_id, c.keep_id, LEAST(SUM(i.quantity), ` + strconv.Itoa(maxItemQuantity) + `) AS quantity
+		 FROM configuration_items i
+		 JOIN listing_copies c ON c.id = i.product_id
+		 WHERE i.slot = ''
+		 GROUP BY i.configuration_id, c.keep_id`,
+		`DELETE FROM configuration_items i
+		 USING listing_copies c
+		 WHERE i.product_id = c.id AND i.slot = '' AND i.id NOT IN (SELECT item_id FROM merged_items)`,
+		`UPDATE configuration_items i SET product_id = m.keep_id, quantity = m.quantity
+		 FROM merged_items m
+		 WHERE i.id = m.item_id`,
+		`UPDATE configuration_items i SET product_id = c.keep_id
+		 FROM listing_copies c
+		 WHERE i.product_id = c.id AND c.id <> c.keep_id`,
+		`UPDATE price_alerts a SET product_id = c.keep_id
+		 FROM listing_copies c
+		 WHERE a.product_id = c.id AND c.id <> c.keep_id`,
+		`DELETE FROM wishlist_items w
+		 USING listing_copies c
+		 WHERE w.product_id = c.id AND EXISTS (
+		     SELECT 1 FROM wishlist_items o
+		     JOIN listing_copies oc ON oc.id = o.product_id
+		     WHERE o.wishlist_id = w.wishlist_id AND oc.keep_id = c.keep_id AND o.id < w.id
+		 )`,
+		`UPDATE wishlist_items w SET product_id = c.keep_id
+		 FROM listing_copies c
+		 WHERE w.product_id = c.id AND c.id <> c.keep_id`,
+		`DELETE FROM products p
+		 USING listing_copies c
+		 WHERE p.id = c.id AND c.id <> c.keep_id`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS products_store_link_idx ON products (store, link) WHERE link <> ''`,
+	} {
+		if _, err := tx.Exec(query); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
 func (s *PostgressStore) createUserTable() error {
 	_, err := s.db.Exec(`
 		CREATE TABLE IF NOT EXISTS users (
 			id SERIAL PRIMARY KEY,
 			email TEXT UNIQUE NOT NULL,
 			password TEXT NOT NULL
-		)
+		);
+		-- Accounts created before email verification existed count as
+		-- verified, so their price alerts keep being sent.
+		DO $$
+		BEGIN
+			IF NOT EXISTS (
+				SELECT 1 FROM information_schema.columns
+				WHERE table_schema = current_schema() AND table_name = 'users' AND column_name = 'verified_at'
+			) THEN
+				ALTER TABLE users ADD COLUMN verified_at TIMESTAMPTZ;
+				UPDATE users SET verified_at = NOW();
+			END IF;
+		END $$;
+		ALTER TABLE users ADD COLUMN IF NOT EXISTS verification_sent_at TIMESTAMPTZ;
+		ALTER TABLE users ADD COLUMN IF NOT EXISTS token_version INT NOT NULL DEFAULT 0;
+		ALTER TABLE users ADD COLUMN IF NOT EXISTS role TEXT NOT NULL DEFAULT 'user'
+			CHECK (role IN ('user', 'editor', 'admin'));
 	`)
+	if err != nil {
+		return err
+	}
+	return s.createUserEmailIndex()
+}
+
+// createUserEmailIndex makes emails unique regardless of case. Accounts
+// created before emails were normalized may differ only in case; startup
+// then fails, naming the clashing user IDs, until they are merged or
+// deleted by hand, since registration cannot be kept consistent without
+// the index.
+func (s *PostgressStore) createUserEmailIndex() error {
+	rows, err := s.db.Query(`
+		SELECT array_agg(id ORDER BY id) FROM users
+		GROUP BY LOWER(email)
+		HAVING COUNT(*) > 1
+		ORDER BY MIN(id)
+	`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var duplicates []string
+	for rows.Next() {
+		var ids pq.Int64Array
+		if err := rows.Scan(&ids); err != nil {
+			return err
+		}
+		duplicates = append(duplicates, fmt.Sprint([]int64(ids)))
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(duplicates) > 0 {
+		return fmt.Errorf("%d email address(es) belong to several accounts, by user ID %s; merge or delete them to enforce case-insensitive unique emails",
+			len(duplicates), strings.Join(duplicates, ", "))
+	}
+
+	_, err = s.db.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS users_email_lower_idx ON users (LOWER(email))`)
 	return err
 }
 
@@ -109,8 +343,12 @@ func (s *PostgressStore) CreateComputerConfigurationsTable() error {
         user_id INTEGER NOT NULL REFERENCES users(id),
         name TEXT NOT NULL,
         total_price BIGINT NOT NULL DEFAULT 0,
-        created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+        created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+        visibility TEXT NOT NULL DEFAULT 'private',
+        share_slug TEXT UNIQUE
     );
+    ALTER TABLE computer_configurations ADD COLUMN IF NOT EXISTS visibility TEXT NOT NULL DEFAULT 'private';
+    ALTER TABLE computer_configurations ADD COLUMN IF NOT EXISTS share_slug TEXT UNIQUE;
     `
 	_, err := s.db.Exec(query)
 	return err
@@ -122,25 +360,217 @@ func (s *PostgressStore) CreateConfigurationItemsTable() error {
         id SERIAL PRIMARY KEY,
         configuration_id INTEGER NOT NULL REFERENCES computer_configurations(id) ON DELETE CASCADE,
         product_id INTEGER NOT NULL REFERENCES products(id),
-        UNIQUE(configuration_id, product_id)
+        quantity INTEGER NOT NULL DEFAULT 1 CHECK (quantity > 0),
+        slot TEXT NOT NULL DEFAULT ''
     );
+    ALTER TABLE configuration_items ADD COLUMN IF NOT EXISTS quantity INTEGER NOT NULL DEFAULT 1 CHECK (quantity > 0);
+    ALTER TABLE configuration_items ADD COLUMN IF NOT EXISTS slot TEXT NOT NULL DEFAULT '';
+    ALTER TABLE configuration_items DROP CONSTRAINT IF EXISTS configuration_items_configuration_id_product_id_key;
+    CREATE UNIQUE INDEX IF NOT EXISTS configuration_items_slot_idx
+        ON configuration_items (configuration_id, slot) WHERE slot <> '';
+    CREATE UNIQUE INDEX IF NOT EXISTS configuration_items_unslotted_idx
+        ON configuration_items (configuration_id, product_id) WHERE slot = '';
     `
 	_, err := s.db.Exec(query)
 	return err
 }
 
 func (s *PostgressStore) CreateProduct(p *Product) error {
+	return s.db.QueryRow(`
+		INSERT INTO products (title, manufacturer, price, code, warranty, link, category, description, image, store,
+		                      availability, delivery_estimate)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		RETURNING id
+	`, p.Title, p.Manufacturer, p.Price, p.Code, p.Warranty, p.Link, p.Category, p.Description, p.Image, p.Store,
+		p.Availability, p.DeliveryEstimate).Scan(&p.ID)
+}
+
+// UpsertProduct updates the listing with the same store and link as p, or
+// inserts p when the store does not have it yet, so that imports keep
+// product IDs stable and refresh prices in place. It reports the price
+// change when an existing listing got a new price. Listings without a link
+// cannot be recognized on the next import and are always inserted.
+func (s *PostgressStore) UpsertProduct(p *Product) (*PriceChange, error) {
+	if p.Link == "" {
+		return nil, s.CreateProduct(p)
+	}
+
+	var oldPrice sql.NullInt64
+	var newPrice int64
+	err := s.db.QueryRow(upsertProductQuery,
+		p.Title, p.Manufacturer, p.Price, p.Code, p.Warranty, p.Link, p.Category, p.Description, p.Image, p.Store,
+		p.Availability, p.DeliveryEstimate).Scan(&p.ID, &oldPrice, &newPrice)
+	if err != nil || !oldPrice.Valid || oldPrice.Int64 == newPrice {
+		return nil, err
+	}
+	return &PriceChange{ProductID: p.ID, OldPrice: oldPrice.Int64, NewPrice: newPrice}, nil
+}
+
+// upsertProductQuery leaves fields that an admin has curated untouched.
+var upsertProductQuery = fmt.Sprintf(`
+		WITH old AS (SELECT price FROM products WHERE store = $10 AND link = $6 AND link <> '')
+		INSERT INTO products AS u (title, manufacturer, price, code, warranty, link, category, description, image, store,
+		                           availability, delivery_estimate)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		ON CONFLICT (store, link) WHERE link <> '' DO UPDATE
+		SET title = %s, manufacturer = %s, price = %s, code = %s, warranty = %s,
+		    category = %s, description = %s, image = %s, availability = %s, delivery_estimate = %s,
+		    last_seen_at = NOW()
+		RETURNING u.id, (SELECT price FROM old), COALESCE(u.price, 0)
+	`, unlessOverridden("title", "$1"), unlessOverridden("manufacturer", "$2"), unlessOverridden("price", "$3"),
+	unlessOverridden("code", "$4"), unlessOverridden("warranty", "$5"), unlessOverridden("category", "$7"),
+	unlessOverridden("description", "$8"), unlessOverridden("image", "$9"),
+	unlessOverridden("availability", "$11"), unlessOverridden("delivery_estimate", "$12"))
+
+func unlessOverridden(column, value string) string {
+	return fmt.Sprintf("CASE WHEN '%s' = ANY(u.overridden_fields) THEN u.%s ELSE %s END", column, column, value)
+}
+
+// CreateConfiguration creates an empty configuration and records its first
+// revision.
+func (s *PostgressStore) CreateConfiguration(userID int, name string) (int, error) {
+	return s.CreateConfigurationWithItems(userID, name, nil, "create")
+}
+
+const configurationColumns = "id, user_id, name, visibility, COALESCE(share_slug, '')"
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanIntoConfiguration(row rowScanner) (*ComputerConfiguration, error) {
+	c := new(ComputerConfiguration)
+	err := row.Scan(&c.ID, &c.UserID, &c.Name, &c.Visibility, &c.ShareSlug)
+	return c, err
+}
+
+func (s *PostgressStore) GetConfigurationByID(id int) (*ComputerConfiguration, error) {
+	return s.getConfiguration("id = $1", id)
+}
+
+// GetConfigurationBySlug returns the shared configuration with the given
+// slug, or nil if there is none or it has been made private again.
+func (s *PostgressStore) GetConfigurationBySlug(slug string) (*ComputerConfiguration, error) {
+	return s.getConfiguration("share_slug = $1 AND visibility <> 'private'", slug)
+}
+
+func (s *PostgressStore) getConfiguration(where string, arg any) (*ComputerConfiguration, error) {
+	row := s.db.QueryRow("SELECT "+configurationColumns+" FROM computer_configurations WHERE "+where, arg)
+	c, err := scanIntoConfiguration(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if err := s.loadConfigurationProducts(c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// SetConfigurationVisibility changes who can see a configuration. The
+// configuration keeps its existing share slug unless slug is non-empty, in
+// which case it replaces it; the slug in effect is returned.
+func (s *PostgressStore) SetConfigurationVisibility(id int, visibility, slug string) (string, error) {
+	var current sql.NullString
+	err := s.db.QueryRow(`
+        UPDATE computer_configurations
+        SET visibility = $2, share_slug = COALESCE(NULLIF($3, ''), share_slug)
+        WHERE id = $1
+        RETURNING share_slug
+    `, id, visibility, slug).Scan(&current)
+	return current.String, err
+}
+
+func (s *PostgressStore) GetPublicConfigurations(limit int) ([]*ComputerConfiguration, error) {
+	return s.getConfigurations(`
+        SELECT `+configurationColumns+`
+        FROM computer_configurations
+        WHERE visibility = 'public'
+        ORDER BY created_at DESC
+        LIMIT $1
+    `, limit)
+}
+
+func (s *PostgressStore) RenameConfiguration(id int, name string) error {
 	_, err := s.db.Exec(`
-		INSERT INTO products (title, manufacturer, price, code, warranty, link, category, description, image, store)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
-	`, p.Title, p.Manufacturer, p.Price, p.Code, p.Warranty, p.Link, p.Category, p.Description, p.Image, p.Store)
+        UPDATE computer_configurations SET name = $2 WHERE id = $1
+    `, id, name)
+	return err
+}
 
+func (s *PostgressStore) DeleteConfiguration(id int) error {
+	_, err := s.db.Exec(`
+        DELETE FROM computer_configurations WHERE id = $1
+    `, id)
 	return err
 }
 
-func (s *PostgressStore) CreateConfiguration(userID int, name string) (int, error) {
+// DuplicateConfiguration copies the configuration and all of its items to a
+// new configuration owned by userID and returns the new ID.
+func (s *PostgressStore) DuplicateConfiguration(id, userID int, name, action string) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var newID int
+	err = tx.QueryRow(`
+        INSERT INTO computer_configurations (user_id, name)
+        SELECT $2, $3
+        FROM computer_configurations
+        WHERE id = $1
+        RETURNING id
+    `, id, userID, name).Scan(&newID)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(`
+        INSERT INTO configuration_items (configuration_id, product_id, quantity, slot)
+        SELECT $2, product_id, quantity, slot
+        FROM configuration_items
+        WHERE configuration_id = $1
+    `, id, newID)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := finishConfigurationChange(tx, newID, userID, action); err != nil {
+		return 0, err
+	}
+	return newID, tx.Commit()
+}
+
+// execer is implemented by both *sql.DB and *sql.Tx.
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// AddProductToConfiguration adds quantity units of a product. Without a slot
+// the quantity is added to an existing unslotted row for the same product,
+// up to maxItemQuantity; with a slot the product replaces whatever currently
+// fills that slot.
+func (s *PostgressStore) AddProductToConfiguration(configID, productID, quantity int, slot string, actorID int, action string) error {
+	return s.changeConfiguration(configID, actorID, action, func(tx *sql.Tx) error {
+		return addConfigurationItem(tx, configID, productID, quantity, slot)
+	})
+}
+
+// CreateConfigurationWithItems creates a configuration holding the given
+// items in one transaction, so a failing item leaves nothing behind.
+func (s *PostgressStore) CreateConfigurationWithItems(userID int, name string, items []*ConfigurationItem, action string) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
 	var configID int
-	err := s.db.QueryRow(`
+	err = tx.QueryRow(`
         INSERT INTO computer_configurations (user_id, name)
         VALUES ($1, $2)
         RETURNING id
@@ -148,28 +578,135 @@ func (s *PostgressStore) CreateConfiguration(userID int, name string) (int, erro
 	if err != nil {
 		return 0, err
 	}
-	return configID, nil
+	for _, item := range items {
+		if err := addConfigurationItem(tx, configID, item.ProductID, item.Quantity, item.Slot); err != nil {
+			return 0, err
+		}
+	}
+	if err := finishConfigurationChange(tx, configID, userID, action); err != nil {
+		return 0, err
+	}
+	return configID, tx.Commit()
 }
 
-func (s *PostgressStore) AddProductToConfiguration(configID, productID int) error {
-	_, err := s.db.Exec(`
-        INSERT INTO configuration_items (configuration_id, product_id)
-        VALUES ($1, $2)
-        ON CONFLICT DO NOTHING
-    `, configID, productID)
+// changeConfiguration runs change in a transaction that also updates the
+// total of the configuration and records the revision, so that the history
+// never misses a change.
+func (s *PostgressStore) changeConfiguration(configID, actorID int, action string, change func(tx *sql.Tx) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := change(tx); err != nil {
+		return err
+	}
+	if err := finishConfigurationChange(tx, configID, actorID, action); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func finishConfigurationChange(tx *sql.Tx, configID, actorID int, action string) error {
+	if err := updateConfigurationTotal(tx, configID); err != nil {
+		return err
+	}
+	return recordConfigurationRevision(tx, configID, actorID, action)
+}
+
+func addConfigurationItem(db execer, configID, productID, quantity int, slot string) error {
+	if slot == "" {
+		_, err := db.Exec(`
+            INSERT INTO configuration_items (configuration_id, product_id, quantity)
+            VALUES ($1, $2, $3)
+            ON CONFLICT (configuration_id, product_id) WHERE slot = ''
+            DO UPDATE SET quantity = LEAST(configuration_items.quantity + EXCLUDED.quantity, $4)
+        `, configID, productID, quantity, maxItemQuantity)
+		return err
+	}
+	_, err := db.Exec(`
+        INSERT INTO configuration_items (configuration_id, product_id, quantity, slot)
+        VALUES ($1, $2, $3, $4)
+        ON CONFLICT (configuration_id, slot) WHERE slot <> ''
+        DO UPDATE SET product_id = EXCLUDED.product_id, quantity = EXCLUDED.quantity
+    `, configID, productID, quantity, slot)
+	return err
+}
+
+func (s *PostgressStore) SetConfigurationItemQuantity(configID, itemID, quantity, actorID int) error {
+	return s.changeConfiguration(configID, actorID, "set_quantity", func(tx *sql.Tx) error {
+		res, err := tx.Exec(`
+            UPDATE configuration_items SET quantity = $3
+            WHERE configuration_id = $1 AND id = $2
+        `, configID, itemID, quantity)
+		return requireAffected(res, err)
+	})
+}
+
+func (s *PostgressStore) RemoveConfigurationItem(configID, itemID, actorID int) error {
+	return s.changeConfiguration(configID, actorID, "remove_item", func(tx *sql.Tx) error {
+		res, err := tx.Exec(`
+            DELETE FROM configuration_items
+            WHERE configuration_id = $1 AND id = $2
+        `, configID, itemID)
+		return requireAffected(res, err)
+	})
+}
+
+func (s *PostgressStore) RemoveProductFromConfiguration(configID, productID, actorID int) error {
+	return s.changeConfiguration(configID, actorID, "remove_product", func(tx *sql.Tx) error {
+		res, err := tx.Exec(`
+            DELETE FROM configuration_items
+            WHERE configuration_id = $1 AND product_id = $2
+        `, configID, productID)
+		return requireAffected(res, err)
+	})
+}
+
+// requireAffected turns a statement that changed no rows into sql.ErrNoRows.
+func requireAffected(res sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// configurationTotal is the SQL expression for the price of configuration c,
+// taking item quantities into account.
+const configurationTotal = `COALESCE((
+            SELECT SUM(p.price * ci.quantity)
+            FROM configuration_items ci
+            JOIN products p ON p.id = ci.product_id
+            WHERE ci.configuration_id = c.id
+        ), 0)`
+
+func updateConfigurationTotal(db execer, configID int) error {
+	_, err := db.Exec(`
+        UPDATE computer_configurations c
+        SET total_price = `+configurationTotal+`
+        WHERE c.id = $1
+    `, configID)
 	return err
 }
 
-func (s *PostgressStore) RemoveProductFromConfiguration(configID, productID int) error {
+// RefreshConfigurationTotals recomputes the stored total of every
+// configuration. It is run after imports, when product prices may change.
+func (s *PostgressStore) RefreshConfigurationTotals() error {
 	_, err := s.db.Exec(`
-        DELETE FROM configuration_items
-        WHERE configuration_id = $1 AND product_id = $2
-    `, configID, productID)
+        UPDATE computer_configurations c
+        SET total_price = ` + configurationTotal + `
+    `)
 	return err
 }
 
 func (s *PostgressStore) GetProducts() ([]*Product, error) {
-	rows, err := s.db.Query("SELECT * FROM products")
+	rows, err := s.db.Query("SELECT " + productColumns + " FROM products")
 	if err != nil {
 		return nil, err
 	}
@@ -187,7 +724,10 @@ func (s *PostgressStore) GetProducts() ([]*Product, error) {
 	return products, nil
 }
 
-func scanIntoProduct(rows *sql.Rows) (*Product, error) {
+const productColumns = "id, title, manufacturer, price, code, warranty, link, category, description, image, store, " +
+	"availability, delivery_estimate"
+
+func scanIntoProduct(rows rowScanner) (*Product, error) {
 	product := new(Product)
 	err := rows.Scan(
 		&product.ID,
@@ -201,80 +741,50 @@ func scanIntoProduct(rows *sql.Rows) (*Product, error) {
 		&product.Description,
 		&product.Image,
 		&product.Store,
+		&product.Availability,
+		&product.DeliveryEstimate,
 	)
 
 	return product, err
 }
 
-func (s *PostgressStore) GetFilteredProducts(category, manufacturer, store, minPrice, maxPrice, title, pageStr, pageSizeStr string) ([]*Product, int, error) {
-	baseQuery := " FROM products WHERE 1=1"
-	args := []interface{}{}
-	argIndex := 1
+// GetFilteredProducts returns one page of products matching q, the total
+// number of matches (or -1 when q.SkipCount is set) and a cursor for the next
+// page, empty when there are no more results. A non-empty q.After switches
+// from page/pageSize to keyset pagination.
+func (s *PostgressStore) GetFilteredProducts(q *ProductQuery) ([]*Product, int, string, error) {
+	orderBy, ok := productSorts[q.Sort]
+	if !ok {
+		return nil, 0, "", fmt.Errorf("unknown sort %q", q.Sort)
+	}
 
-	filterQuery := ""
+	filter := q.where()
 
-	if category != "" {
-		filterQuery += fmt.Sprintf(" AND category = $%d", argIndex)
-		args = append(args, category)
-		argIndex++
-	}
-	if manufacturer != "" {
-		manufacturers := strings.Split(manufacturer, ",")
-		placeholders := []string{}
-		for _, m := range manufacturers {
-			placeholders = append(placeholders, fmt.Sprintf("$%d", argIndex))
-			args = append(args, strings.TrimSpace(m))
-			argIndex++
+	totalCount := -1
+	if !q.SkipCount {
+		err := s.db.QueryRow("SELECT COUNT(*) FROM products"+filter.query, filter.args...).Scan(&totalCount)
+		if err != nil {
+			return nil, 0, "", err
 		}
-		filterQuery += fmt.Sprintf(" AND manufacturer IN (%s)", strings.Join(placeholders, ","))
-	}
-	if store != "" {
-		filterQuery += fmt.Sprintf(" AND store = $%d", argIndex)
-		args = append(args, store)
-		argIndex++
-	}
-	if minPrice != "" {
-		filterQuery += fmt.Sprintf(" AND price >= $%d", argIndex)
-		args = append(args, minPrice)
-		argIndex++
-	}
-	if maxPrice != "" {
-		filterQuery += fmt.Sprintf(" AND price <= $%d", argIndex)
-		args = append(args, maxPrice)
-		argIndex++
-	}
-	if title != "" {
-		filterQuery += fmt.Sprintf(" AND title ILIKE $%d", argIndex)
-		args = append(args, "%"+title+"%")
-		argIndex++
 	}
 
-	countQuery := "SELECT COUNT(*)" + baseQuery + filterQuery
-	var totalCount int
-	err := s.db.QueryRow(countQuery, args...).Scan(&totalCount)
-	if err != nil {
-		return nil, 0, err
-	}
-
-	page := 1
-	pageSize := 20
-	if pageStr != "" {
-		fmt.Sscanf(pageStr, "%d", &page)
-	}
-	if pageSizeStr != "" {
-		fmt.Sscanf(pageSizeStr, "%d", &pageSize)
+	offset := (q.Page - 1) * q.PageSize
+	if q.After != "" {
+		cursor, err := decodeProductCursor(q.After, q.Sort)
+		if err != nil {
+			return nil, 0, "", err
+		}
+		cursor.keysetCondition(filter)
+		offset = 0
 	}
-	offset := (page - 1) * pageSize
 
-	filteredArgs := make([]interface{}, len(args))
-	copy(filteredArgs, args)
-	filteredArgs = append(filteredArgs, pageSize, offset)
+	// One extra row tells us whether another page follows.
+	dataQuery := "SELECT " + productColumns + " FROM products" + filter.query + " ORDER BY " + orderBy +
+		fmt.Sprintf(" LIMIT %s OFFSET %s", filter.nextArg(q.PageSize+1), filter.nextArg(offset))
 
-	dataQuery := "SELECT *" + baseQuery + filterQuery + fmt.Sprintf(" LIMIT $%d OFFSET $%d", argIndex, argIndex+1)
-
-	rows, err := s.db.Query(dataQuery, filteredArgs...)
+	rows, err := s.db.Query(dataQuery, filter.args...)
 	if err != nil {
-		return nil, 0, err
+		return nil, 0, "", err
 	}
 	defer rows.Close()
 
@@ -282,157 +792,190 @@ func (s *PostgressStore) GetFilteredProducts(category, manufacturer, store, minP
 	for rows.Next() {
 		product, err := scanIntoProduct(rows)
 		if err != nil {
-			return nil, 0, err
+			return nil, 0, "", err
 		}
 		products = append(products, product)
 	}
 
-	return products, totalCount, nil
+	nextCursor := ""
+	if len(products) > q.PageSize {
+		products = products[:q.PageSize]
+		nextCursor = encodeProductCursor(q.Sort, products[len(products)-1])
+	}
+
+	return products, totalCount, nextCursor, nil
 }
 
-func (s *PostgressStore) GetUniqueManufacturers() ([]string, error) {
-	rows, err := s.db.Query("SELECT DISTINCT manufacturer FROM products WHERE manufacturer IS NOT NULL AND manufacturer != ''")
+func (s *PostgressStore) GetProductByID(id int) (*Product, error) {
+	row := s.db.QueryRow("SELECT "+productColumns+" FROM products WHERE id = $1", id)
+	product, err := scanIntoProduct(row)
 	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var manufacturers []string
-	for rows.Next() {
-		var m string
-		if err := rows.Scan(&m); err != nil {
-			return nil, err
+		if err == sql.ErrNoRows {
+			return nil, nil
 		}
-		manufacturers = append(manufacturers, m)
+		return nil, err
 	}
-	return manufacturers, nil
+	return product, nil
 }
 
-func (s *PostgressStore) GetManufacturersByCategory(category string) ([]string, error) {
+func (s *PostgressStore) GetProductsByConfigurationID(configID int) ([]*Product, error) {
 	rows, err := s.db.Query(`
-		SELECT DISTINCT manufacturer
-		FROM products
-		WHERE category = $1 AND manufacturer IS NOT NULL AND manufacturer <> ''
-	`, category)
+		SELECT p.id, p.title, p.description, p.price,
+		       p.category, p.code, p.image, p.link, p.manufacturer, p.store, p.warranty,
+		       p.availability, p.delivery_estimate
+		FROM products p
+		JOIN configuration_items ci ON ci.product_id = p.id
+		WHERE ci.configuration_id = $1
+	`, configID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var manufacturers []string
+	var products []*Product
 	for rows.Next() {
-		var m string
-		if err := rows.Scan(&m); err != nil {
+		var p Product
+		if err := rows.Scan(
+			&p.ID, &p.Title, &p.Description, &p.Price,
+			&p.Category, &p.Code, &p.Image, &p.Link, &p.Manufacturer, &p.Store, &p.Warranty,
+			&p.Availability, &p.DeliveryEstimate,
+		); err != nil {
 			return nil, err
 		}
-		manufacturers = append(manufacturers, m)
+		products = append(products, &p)
 	}
+	return products, nil
+}
 
-	return manufacturers, nil
+func (s *PostgressStore) GetConfigurationsByUserID(userID int) ([]*ComputerConfiguration, error) {
+	return s.getConfigurations(`
+		SELECT `+configurationColumns+`
+		FROM computer_configurations
+		WHERE user_id = $1
+		ORDER BY id
+	`, userID)
 }
 
-func (s *PostgressStore) GetUniqueStores() ([]string, error) {
-	rows, err := s.db.Query("SELECT DISTINCT store FROM products WHERE store IS NOT NULL AND store != ''")
+func (s *PostgressStore) getConfigurations(query string, args ...any) ([]*ComputerConfiguration, error) {
+	rows, err := s.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var stores []string
+	var configs []*ComputerConfiguration
 	for rows.Next() {
-		var m string
-		if err := rows.Scan(&m); err != nil {
+		c, err := scanIntoConfiguration(rows)
+		if err != nil {
+			return nil, err
+		}
+		configs = append(configs, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, c := range configs {
+		if err := s.loadConfigurationProducts(c); err != nil {
 			return nil, err
 		}
-		stores = append(stores, m)
 	}
-	return stores, nil
+	return configs, nil
 }
 
-func (s *PostgressStore) GetProductByID(id int) (*Product, error) {
-	row := s.db.QueryRow("SELECT * FROM products WHERE id = $1", id)
-	product := new(Product)
-	err := row.Scan(
-		&product.ID,
-		&product.Title,
-		&product.Manufacturer,
-		&product.Price,
-		&product.Code,
-		&product.Warranty,
-		&product.Link,
-		&product.Category,
-		&product.Description,
-		&product.Image,
-		&product.Store,
-	)
+// loadConfigurationProducts fetches the items of c and computes its totals.
+func (s *PostgressStore) loadConfigurationProducts(c *ComputerConfiguration) error {
+	items, err := s.GetConfigurationItems(c.ID)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, nil
-		}
-		return nil, err
+		return err
 	}
-	return product, nil
+	c.Items = items
+
+	c.Products = make([]*Product, len(items))
+	ids := make([]int, len(items))
+	for i, item := range items {
+		c.Products[i] = item.Product
+		ids[i] = item.ProductID
+	}
+	equivalents, err := s.GetEquivalentProducts(ids)
+	if err != nil {
+		return err
+	}
+
+	priceConfiguration(c, equivalents)
+	return nil
 }
 
-func (s *PostgressStore) GetProductsByConfigurationID(configID int) ([]*Product, error) {
+func (s *PostgressStore) GetConfigurationItems(configID int) ([]*ConfigurationItem, error) {
 	rows, err := s.db.Query(`
-		SELECT p.id, p.title, p.description, p.price,
-		       p.category, p.code, p.image, p.link, p.manufacturer, p.store, p.warranty
-		FROM products p
-		JOIN configuration_items ci ON ci.product_id = p.id
+		SELECT ci.id, ci.quantity, ci.slot,
+		       p.id, p.title, p.manufacturer, p.price, p.code, p.warranty,
+		       p.link, p.category, p.description, p.image, p.store, p.availability, p.delivery_estimate
+		FROM configuration_items ci
+		JOIN products p ON p.id = ci.product_id
 		WHERE ci.configuration_id = $1
+		ORDER BY ci.slot = '', ci.slot, ci.id
 	`, configID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var products []*Product
+	items := []*ConfigurationItem{}
 	for rows.Next() {
-		var p Product
+		item := &ConfigurationItem{Product: new(Product)}
+		p := item.Product
 		if err := rows.Scan(
-			&p.ID, &p.Title, &p.Description, &p.Price,
-			&p.Category, &p.Code, &p.Image, &p.Link, &p.Manufacturer, &p.Store, &p.Warranty,
+			&item.ID, &item.Quantity, &item.Slot,
+			&p.ID, &p.Title, &p.Manufacturer, &p.Price, &p.Code, &p.Warranty,
+			&p.Link, &p.Category, &p.Description, &p.Image, &p.Store, &p.Availability, &p.DeliveryEstimate,
 		); err != nil {
 			return nil, err
 		}
-		products = append(products, &p)
+		item.ProductID = p.ID
+		items = append(items, item)
 	}
-	return products, nil
+	return items, rows.Err()
 }
 
-func (s *PostgressStore) GetConfigurationsByUserID(userID int) ([]*ComputerConfiguration, error) {
+// GetEquivalentProducts returns, for each of the given products, the listings
+// of the same part in other stores. Listings are matched by product code.
+func (s *PostgressStore) GetEquivalentProducts(productIDs []int) (map[int][]*Product, error) {
+	equivalents := map[int][]*Product{}
+	if len(productIDs) == 0 {
+		return equivalents, nil
+	}
+
 	rows, err := s.db.Query(`
-		SELECT id, user_id, name
-		FROM computer_configurations
-		WHERE user_id = $1
-	`, userID)
+		SELECT src.id, p.id, p.title, p.manufacturer, p.price, p.code, p.warranty,
+		       p.link, p.category, p.description, p.image, p.store, p.availability, p.delivery_estimate
+		FROM products src
+		JOIN products p ON p.store <> src.store
+		                AND LOWER(TRIM(p.code)) = LOWER(TRIM(src.code))
+		WHERE src.id = ANY($1) AND TRIM(src.code) <> ''
+	`, pq.Array(productIDs))
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var configs []*ComputerConfiguration
 	for rows.Next() {
-		var c ComputerConfiguration
-		if err := rows.Scan(&c.ID, &c.UserID, &c.Name); err != nil {
-			return nil, err
-		}
-
-		products, err := s.GetProductsByConfigurationID(c.ID)
-		if err != nil {
+		var srcID int
+		p := new(Product)
+		if err := rows.Scan(
+			&srcID, &p.ID, &p.Title, &p.Manufacturer, &p.Price, &p.Code, &p.Warranty,
+			&p.Link, &p.Category, &p.Description, &p.Image, &p.Store, &p.Availability, &p.DeliveryEstimate,
+		); err != nil {
 			return nil, err
 		}
-		c.Products = products
-
-		configs = append(configs, &c)
+		equivalents[srcID] = append(equivalents[srcID], p)
 	}
-	return configs, nil
+	return equivalents, rows.Err()
 }
 
 func (s *PostgressStore) GetRandomProducts(limit int) ([]*Product, error) {
 	query := `
-        SELECT * FROM products
+        SELECT ` + productColumns + ` FROM products
         ORDER BY RANDOM()
         LIMIT $1
     `
@@ -452,3 +995,107 @@ func (s *PostgressStore) GetRandomProducts(limit int) ([]*Product, error) {
 	}
 	return products, nil
 }
+
+func (s *PostgressStore) GetProductByLink(link string) (*Product, error) {
+	row := s.db.QueryRow("SELECT "+productColumns+" FROM products WHERE link = $1 ORDER BY price LIMIT 1", link)
+	product, err := scanIntoProduct(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return product, nil
+}
+
+// GetProductsByCode returns all listings whose code matches, ignoring case,
+// cheapest first.
+func (s *PostgressStore) GetProductsByCode(code string) ([]*Product, error) {
+	return s.queryProducts(`
+		SELECT `+productColumns+`
+		FROM products
+		WHERE TRIM(code) <> '' AND LOWER(TRIM(code)) = LOWER(TRIM($1))
+		ORDER BY price
+	`, code)
+}
+
+// SearchProductsByTitleTokens returns products whose titles contain the most
+// of the given tokens, best matches first.
+func (s *PostgressStore) SearchProductsByTitleTokens(tokens []string, limit int) ([]*Product, error) {
+	patterns := make([]string, len(tokens))
+	for i, t := range tokens {
+		patterns[i] = "%" + t + "%"
+	}
+	return s.queryProducts(`
+		SELECT `+productColumns+`
+		FROM products p
+		WHERE p.title ILIKE ANY($1)
+		ORDER BY (SELECT COUNT(*) FROM unnest($1::text[]) t WHERE p.title ILIKE t) DESC, p.price
+		LIMIT $2
+	`, pq.Array(patterns), limit)
+}
+
+// SearchProductsByKeywords returns products whose category or title contains
+// any of the keywords, cheapest first.
+func (s *PostgressStore) SearchProductsByKeywords(keywords []string, limit int) ([]*Product, error) {
+	patterns := make([]string, len(keywords))
+	for i, k := range keywords {
+		patterns[i] = "%" + k + "%"
+	}
+	return s.queryProducts(`
+		SELECT `+productColumns+`
+		FROM products
+		WHERE category ILIKE ANY($1) OR title ILIKE ANY($1)
+		ORDER BY price
+		LIMIT $2
+	`, pq.Array(patterns), limit)
+}
+
+// GetComponentCandidates returns purchasable products of one component kind,
+// as classified by the category taxonomy, priced up to maxPrice, cheapest
+// first. When stores is not empty only their listings are considered. Past
+// the limit the cheapest quarter is kept whole and the rest is sampled evenly
+// across the price range, so both the cheap and the expensive end stay
+// available to the generator.
+func (s *PostgressStore) GetComponentCandidates(kind string, stores []string, maxPrice int64, limit int) ([]*Product, error) {
+	q := &ProductQuery{CategorySlugs: []string{componentCategories[kind]}, MaxPrice: &maxPrice}
+	f := q.where()
+	f.query += " AND price > 0"
+	f.add("availability <> %s", AvailabilityOutOfStock)
+	if len(stores) > 0 {
+		lowered := make([]string, len(stores))
+		for i, store := range stores {
+			lowered[i] = strings.ToLower(store)
+		}
+		f.add("LOWER(store) = ANY(%s)", pq.Array(lowered))
+	}
+	cheapest, sampled := f.nextArg(limit/4), f.nextArg(max(limit-limit/4, 1))
+	return s.queryProducts(`
+		SELECT `+productColumns+`
+		FROM (
+			SELECT *, ROW_NUMBER() OVER (ORDER BY price, id) AS price_rank, COUNT(*) OVER () AS total
+			FROM products`+f.query+`
+		) candidates
+		WHERE price_rank <= `+cheapest+`
+		   OR (price_rank - 1) % CEIL(total::numeric / `+sampled+`)::int = 0
+		ORDER BY price, id
+	`, f.args...)
+}
+
+func (s *PostgressStore) queryProducts(query string, args ...any) ([]*Product, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	products := []*Product{}
+	for rows.Next() {
+		product, err := scanIntoProduct(rows)
+		if err != nil {
+			return nil, err
+		}
+		products = append(products, product)
+	}
+	return products, rows.Err()
+}
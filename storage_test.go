@@ -0,0 +1,64 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+// testStore connects to the database in TEST_DATABASE_URL and creates the
+// tables. Tests that need it are skipped when no database is configured.
+func testStore(t *testing.T) *PostgressStore {
+	t.Helper()
+	connStr := os.Getenv("TEST_DATABASE_URL")
+	if connStr == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	db, err := sql.Open("postgres", connStr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	s := &PostgressStore{db: db}
+	for _, create := range []func() error{
+		s.createProductsTable, s.createUserTable, s.CreatePasswordResetTokensTable,
+	} {
+		if err := create(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s
+}
+
+// testUser creates a user that is deleted when the test ends.
+func testUser(t *testing.T, s *PostgressStore) *User {
+	t.Helper()
+	user := &User{Email: fmt.Sprintf("test-%d@example.com", time.Now().UnixNano()), Password: "hash"}
+	if err := s.CreateUser(user); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.db.Exec(`DELETE FROM users WHERE id = $1`, user.ID) })
+	return user
+}
+
+func TestRequireAffected(t *testing.T) {
+	if err := requireAffected(driverResult(1), nil); err != nil {
+		t.Errorf("one row: %v", err)
+	}
+	if err := requireAffected(driverResult(0), nil); err != sql.ErrNoRows {
+		t.Errorf("no rows: err = %v, want sql.ErrNoRows", err)
+	}
+	failed := fmt.Errorf("connection lost")
+	if err := requireAffected(nil, failed); err != failed {
+		t.Errorf("failed statement: err = %v", err)
+	}
+}
+
+// driverResult is an sql.Result reporting n affected rows.
+type driverResult int64
+
+func (r driverResult) LastInsertId() (int64, error) { return 0, nil }
+func (r driverResult) RowsAffected() (int64, error) { return int64(r), nil }
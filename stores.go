@@ -0,0 +1,347 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// storeFreshnessWindow is how old a store's last import may be before its
+// prices are considered stale.
+const storeFreshnessWindow = 48 * time.Hour
+
+var feedFormats = []string{"csv"}
+
+func (s *PostgressStore) CreateStoresTable() error {
+	_, err := s.db.Exec(`
+    CREATE TABLE IF NOT EXISTS stores (
+        id SERIAL PRIMARY KEY,
+        slug TEXT NOT NULL UNIQUE,
+        name TEXT NOT NULL UNIQUE,
+        website TEXT NOT NULL DEFAULT '',
+        logo TEXT NOT NULL DEFAULT '',
+        city TEXT NOT NULL DEFAULT '',
+        delivery_info TEXT NOT NULL DEFAULT '',
+        feed_url TEXT NOT NULL DEFAULT '',
+        feed_format TEXT NOT NULL DEFAULT 'csv',
+        last_import_at TIMESTAMPTZ
+    );
+    `)
+	return err
+}
+
+const storeColumns = "st.id, st.slug, st.name, st.website, st.logo, st.city, st.delivery_info, st.last_import_at"
+
+func scanIntoStore(row rowScanner, extra ...any) (*Store, error) {
+	st := new(Store)
+	var lastImport sql.NullTime
+	err := row.Scan(append([]any{
+		&st.ID, &st.Slug, &st.Name, &st.Website, &st.Logo, &st.City, &st.DeliveryInfo, &lastImport,
+	}, extra...)...)
+	if lastImport.Valid {
+		st.LastImportAt = &lastImport.Time
+	}
+	return st, err
+}
+
+// GetStores returns every store with its number of listings.
+func (s *PostgressStore) GetStores() ([]*Store, error) {
+	rows, err := s.db.Query(`
+        SELECT ` + storeColumns + `, (SELECT COUNT(*) FROM products p WHERE p.store = st.name)
+        FROM stores st
+        ORDER BY st.name
+    `)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stores := []*Store{}
+	for rows.Next() {
+		var count int
+		st, err := scanIntoStore(rows, &count)
+		if err != nil {
+			return nil, err
+		}
+		st.ProductCount = count
+		stores = append(stores, st)
+	}
+	return stores, rows.Err()
+}
+
+// GetAdminStore returns the store with the given ID or slug, including its
+// feed configuration, or nil when there is no such store.
+func (s *PostgressStore) GetAdminStore(idOrSlug string) (*AdminStore, error) {
+	st := &AdminStore{}
+	var count int
+	var err error
+	st.Store, err = scanIntoStore(s.db.QueryRow(`
+        SELECT `+storeColumns+`, st.feed_url, st.feed_format,
+               (SELECT COUNT(*) FROM products p WHERE p.store = st.name)
+        FROM stores st
+        WHERE st.id::text = $1 OR st.slug = $1
+    `, idOrSlug), &st.FeedURL, &st.FeedFormat, &count)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	st.ProductCount = count
+	return st, nil
+}
+
+// UpdateStore saves the metadata and feed configuration of st. It returns
+// sql.ErrNoRows when the store does not exist.
+func (s *PostgressStore) UpdateStore(st *AdminStore) error {
+	res, err := s.db.Exec(`
+        UPDATE stores
+        SET website = $2, logo = $3, city = $4, delivery_info = $5, feed_url = $6, feed_format = $7
+        WHERE id = $1
+    `, st.ID, st.Website, st.Logo, st.City, st.DeliveryInfo, st.FeedURL, st.FeedFormat)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// MarkUnseenListingsOutOfStock marks the store's listings that were missing
+// from its latest import as out of stock, unless an admin curates their
+// availability. Listings that come back get their availability from the feed.
+func (s *PostgressStore) MarkUnseenListingsOutOfStock(name string) error {
+	_, err := s.db.Exec(`
+        UPDATE products SET availability = $2
+        WHERE store = $1
+          AND availability <> $2
+          AND NOT 'availability' = ANY(overridden_fields)
+          AND last_seen_at < (SELECT MAX(last_seen_at) FROM products WHERE store = $1) - INTERVAL '1 hour'
+    `, name, AvailabilityOutOfStock)
+	return err
+}
+
+// RecordStoreImport notes a successful import from the store with the given
+// name, adding it to the directory the first time it is seen.
+func (s *PostgressStore) RecordStoreImport(name string) error {
+	base := slugify(name, "store")
+	for n := 1; n <= 100; n++ {
+		res, err := s.db.Exec(`UPDATE stores SET last_import_at = NOW() WHERE name = $1`, name)
+		if err != nil {
+			return err
+		}
+		if updated, err := res.RowsAffected(); err != nil || updated > 0 {
+			return err
+		}
+
+		slug := base
+		if n > 1 {
+			slug = fmt.Sprintf("%s-%d", base, n)
+		}
+		res, err = s.db.Exec(`
+            INSERT INTO stores (slug, name, last_import_at) VALUES ($1, $2, NOW())
+            ON CONFLICT DO NOTHING
+        `, slug, name)
+		if err != nil {
+			return err
+		}
+		if inserted, err := res.RowsAffected(); err != nil || inserted > 0 {
+			return err
+		}
+	}
+	return fmt.Errorf("could not find a free slug for store %q", name)
+}
+
+// GetStoreCategoryCounts returns the number of listings the store has in
+// each category of the taxonomy, largest first.
+func (s *PostgressStore) GetStoreCategoryCounts(name string) ([]*StoreCategoryCount, error) {
+	rows, err := s.db.Query(`
+        SELECT COALESCE(c.slug, ''), COALESCE(c.name_mk, ''), COALESCE(c.name_en, ''), COUNT(*)
+        FROM products p
+        LEFT JOIN categories c ON c.id = p.category_id
+        WHERE p.store = $1
+        GROUP BY c.slug, c.name_mk, c.name_en
+        ORDER BY COUNT(*) DESC, c.slug
+    `, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := []*StoreCategoryCount{}
+	for rows.Next() {
+		c := new(StoreCategoryCount)
+		if err := rows.Scan(&c.Slug, &c.NameMK, &c.NameEN, &c.ProductCount); err != nil {
+			return nil, err
+		}
+		counts = append(counts, c)
+	}
+	return counts, rows.Err()
+}
+
+// GetStorePriceIndex compares the store's prices with the average price of
+// the same parts, matched by code, in the other stores. It returns the mean
+// ratio and the number of parts compared; a ratio below 1 means the store is
+// cheaper.
+func (s *PostgressStore) GetStorePriceIndex(name string) (float64, int, error) {
+	var ratio sql.NullFloat64
+	var compared int
+	err := s.db.QueryRow(`
+        WITH mine AS (
+            SELECT LOWER(TRIM(code)) AS code, MIN(price) AS price
+            FROM products
+            WHERE store = $1 AND TRIM(code) <> '' AND price > 0
+            GROUP BY 1
+        ), others AS (
+            SELECT LOWER(TRIM(code)) AS code, AVG(price) AS price
+            FROM products
+            WHERE store <> $1 AND TRIM(code) <> '' AND price > 0
+            GROUP BY 1
+        )
+        SELECT AVG(mine.price / others.price), COUNT(*)
+        FROM mine JOIN others USING (code)
+    `, name).Scan(&ratio, &compared)
+	return ratio.Float64, compared, err
+}
+
+// GetStoreFreshness returns when the store's listings were last seen in an
+// import and how many were missing from it.
+func (s *PostgressStore) GetStoreFreshness(name string) (*time.Time, int, error) {
+	var lastSeen sql.NullTime
+	var missing int
+	err := s.db.QueryRow(`
+        SELECT MAX(last_seen_at),
+               COUNT(*) FILTER (WHERE last_seen_at < (SELECT MAX(last_seen_at) FROM products WHERE store = $1) - INTERVAL '1 hour')
+        FROM products
+        WHERE store = $1
+    `, name).Scan(&lastSeen, &missing)
+	if err != nil || !lastSeen.Valid {
+		return nil, missing, err
+	}
+	return &lastSeen.Time, missing, nil
+}
+
+func (s *APIServer) handleGetStores(w http.ResponseWriter, r *http.Request) error {
+	stores, err := s.store.GetStores()
+	if err != nil {
+		return fmt.Errorf("failed to fetch stores: %w", err)
+	}
+	return WriteJSON(w, http.StatusOK, stores)
+}
+
+func (s *APIServer) getAdminStore(r *http.Request) (*AdminStore, error) {
+	st, err := s.store.GetAdminStore(mux.Vars(r)["id"])
+	if err != nil {
+		return nil, fmt.Errorf("could not get store: %w", err)
+	}
+	if st == nil {
+		return nil, statusErrorf(http.StatusNotFound, "store not found")
+	}
+	return st, nil
+}
+
+// handleGetStore returns a store with statistics: listings per category, a
+// price index against the other stores and how fresh its data is. The store
+// can be addressed by ID or slug.
+func (s *APIServer) handleGetStore(w http.ResponseWriter, r *http.Request) error {
+	st, err := s.getAdminStore(r)
+	if err != nil {
+		return err
+	}
+	details := &StoreDetails{Store: st.Store}
+
+	if details.Categories, err = s.store.GetStoreCategoryCounts(st.Name); err != nil {
+		return fmt.Errorf("could not count store categories: %w", err)
+	}
+
+	ratio, compared, err := s.store.GetStorePriceIndex(st.Name)
+	if err != nil {
+		return fmt.Errorf("could not compute price index: %w", err)
+	}
+	details.ComparedProducts = compared
+	if compared > 0 {
+		index := math.Round(ratio*1000) / 10
+		details.PriceIndex = &index
+	}
+
+	lastSeen, missing, err := s.store.GetStoreFreshness(st.Name)
+	if err != nil {
+		return fmt.Errorf("could not compute freshness: %w", err)
+	}
+	details.LastSeenAt = lastSeen
+	details.MissingFromLastImport = missing
+	details.Freshness = "unknown"
+	if st.LastImportAt != nil {
+		details.Freshness = "fresh"
+		if time.Since(*st.LastImportAt) > storeFreshnessWindow {
+			details.Freshness = "stale"
+		}
+	}
+
+	return WriteJSON(w, http.StatusOK, details)
+}
+
+func (s *APIServer) handleAdminGetStore(w http.ResponseWriter, r *http.Request) error {
+	st, err := s.getAdminStore(r)
+	if err != nil {
+		return err
+	}
+	return WriteJSON(w, http.StatusOK, st)
+}
+
+func (s *APIServer) handleAdminUpdateStore(w http.ResponseWriter, r *http.Request) error {
+	st, err := s.getAdminStore(r)
+	if err != nil {
+		return err
+	}
+
+	var req struct {
+		Website      *string `json:"website"`
+		Logo         *string `json:"logo"`
+		City         *string `json:"city"`
+		DeliveryInfo *string `json:"deliveryInfo"`
+		FeedURL      *string `json:"feedUrl"`
+		FeedFormat   *string `json:"feedFormat"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return err
+	}
+	set := func(dst *string, v *string) {
+		if v != nil {
+			*dst = strings.TrimSpace(*v)
+		}
+	}
+	set(&st.Website, req.Website)
+	set(&st.Logo, req.Logo)
+	set(&st.City, req.City)
+	set(&st.DeliveryInfo, req.DeliveryInfo)
+	set(&st.FeedURL, req.FeedURL)
+	set(&st.FeedFormat, req.FeedFormat)
+
+	for field, v := range map[string]string{"website": st.Website, "logo": st.Logo, "feedUrl": st.FeedURL} {
+		if v != "" && !isHTTPURL(v) {
+			return statusErrorf(http.StatusUnprocessableEntity, "%s must be an http(s) URL", field)
+		}
+	}
+	if !slices.Contains(feedFormats, st.FeedFormat) {
+		return statusErrorf(http.StatusUnprocessableEntity, "feedFormat must be one of %s", strings.Join(feedFormats, ", "))
+	}
+
+	if err := s.store.UpdateStore(st); err != nil {
+		if err == sql.ErrNoRows {
+			return statusErrorf(http.StatusNotFound, "store not found")
+		}
+		return fmt.Errorf("failed to update store: %w", err)
+	}
+	return WriteJSON(w, http.StatusOK, st)
+}
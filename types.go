@@ -1,5 +1,7 @@
 package main
 
+import "time"
+
 type Product struct {
 	ID           int    `json:"id"`
 	Title        string `json:"title"`
@@ -12,17 +14,204 @@ type Product struct {
 	Description  string `json:"description"`
 	Image        string `json:"image"`
 	Store        string `json:"store"`
+	// Availability is one of the Availability* constants.
+	Availability     string `json:"availability"`
+	DeliveryEstimate string `json:"deliveryEstimate,omitempty"`
+}
+
+// AdminProduct is a product together with the fields an admin has curated,
+// which imports leave alone.
+type AdminProduct struct {
+	*Product
+	OverriddenFields []string `json:"overriddenFields"`
 }
 
 type User struct {
-	ID       int    `json:"id"`
-	Email    string `json:"email"`
-	Password string `json:"-"`
+	ID           int        `json:"id"`
+	Email        string     `json:"email"`
+	Password     string     `json:"-"`
+	VerifiedAt   *time.Time `json:"verifiedAt,omitempty"`
+	TokenVersion int        `json:"-"`
+	Role         string     `json:"role"`
 }
 
 type ComputerConfiguration struct {
-	ID       int        `json:"id"`
-	UserID   int        `json:"userID"`
-	Name     string     `json:"name"`
-	Products []*Product `json:"products"`
+	ID                 int                  `json:"id"`
+	UserID             int                  `json:"userID"`
+	Name               string               `json:"name"`
+	Visibility         string               `json:"visibility"`
+	ShareSlug          string               `json:"shareSlug,omitempty"`
+	Products           []*Product           `json:"products"`
+	Items              []*ConfigurationItem `json:"items"`
+	TotalPrice         int64                `json:"totalPrice"`
+	CheapestTotalPrice int64                `json:"cheapestTotalPrice"`
+	CategoryTotals     map[string]int64     `json:"categoryTotals"`
+	StoreTotals        map[string]int64     `json:"storeTotals"`
+	// Warnings lists the items that cannot be bought right now.
+	Warnings []*ConfigurationWarning `json:"warnings"`
+}
+
+type ConfigurationWarning struct {
+	ItemID       int    `json:"itemID"`
+	ProductID    int    `json:"productID"`
+	Availability string `json:"availability"`
+	Message      string `json:"message"`
+}
+
+type StockSnapshot struct {
+	Availability string    `json:"availability"`
+	Price        int64     `json:"price"`
+	RecordedAt   time.Time `json:"recordedAt"`
+}
+
+type ConfigurationItem struct {
+	ID        int      `json:"id"`
+	ProductID int      `json:"productID"`
+	Slot      string   `json:"slot"`
+	Quantity  int      `json:"quantity"`
+	Product   *Product `json:"product"`
+}
+
+type ConfigurationRevision struct {
+	ID              int             `json:"id"`
+	ConfigurationID int             `json:"configurationID"`
+	ActorID         *int            `json:"actorID"`
+	Action          string          `json:"action"`
+	Items           []*RevisionItem `json:"items"`
+	TotalPrice      int64           `json:"totalPrice"`
+	CreatedAt       time.Time       `json:"createdAt"`
+}
+
+// RevisionItem is a configuration item as it was when a revision was taken.
+type RevisionItem struct {
+	ProductID int    `json:"productID"`
+	Slot      string `json:"slot"`
+	Quantity  int    `json:"quantity"`
+	Price     int64  `json:"price"`
+	Title     string `json:"title"`
+	Store     string `json:"store"`
+}
+
+// PriceChange is a listing whose price changed during an import.
+type PriceChange struct {
+	ProductID int
+	OldPrice  int64
+	NewPrice  int64
+}
+
+type PriceAlert struct {
+	ID             int        `json:"id"`
+	UserID         int        `json:"userID"`
+	ProductID      int        `json:"productID"`
+	TargetPrice    *int64     `json:"targetPrice,omitempty"`
+	DropPercent    *int       `json:"dropPercent,omitempty"`
+	BaselinePrice  int64      `json:"baselinePrice"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	TriggeredAt    *time.Time `json:"triggeredAt,omitempty"`
+	TriggeredPrice *int64     `json:"triggeredPrice,omitempty"`
+	UserEmail      string     `json:"-"`
+	Product        *Product   `json:"product,omitempty"`
+}
+
+type Wishlist struct {
+	ID        int             `json:"id"`
+	UserID    int             `json:"userID"`
+	Name      string          `json:"name"`
+	CreatedAt time.Time       `json:"createdAt"`
+	Items     []*WishlistItem `json:"items"`
+}
+
+type WishlistItem struct {
+	ProductID    int       `json:"productID"`
+	SavedPrice   int64     `json:"savedPrice"`
+	CurrentPrice int64     `json:"currentPrice"`
+	PriceChange  int64     `json:"priceChange"`
+	Availability string    `json:"availability"`
+	AddedAt      time.Time `json:"addedAt"`
+	Product      *Product  `json:"product"`
+}
+
+type AuditEvent struct {
+	ID        int            `json:"id"`
+	Event     string         `json:"event"`
+	UserID    *int           `json:"userID,omitempty"`
+	Email     string         `json:"email,omitempty"`
+	IP        string         `json:"ip,omitempty"`
+	Details   map[string]any `json:"details,omitempty"`
+	CreatedAt time.Time      `json:"createdAt"`
+}
+
+type Category struct {
+	ID           int         `json:"id"`
+	Slug         string      `json:"slug"`
+	ParentID     *int        `json:"parentID,omitempty"`
+	Name         string      `json:"name"`
+	NameMK       string      `json:"nameMk"`
+	NameEN       string      `json:"nameEn"`
+	ProductCount int         `json:"productCount"`
+	Children     []*Category `json:"children,omitempty"`
+}
+
+// CategoryMapping maps a store's own category name onto the taxonomy. An
+// empty Store applies to every store without a specific mapping.
+type CategoryMapping struct {
+	ID       int    `json:"id"`
+	Store    string `json:"store"`
+	Raw      string `json:"raw"`
+	Category string `json:"category"`
+}
+
+type UnmappedCategory struct {
+	Store        string `json:"store"`
+	Raw          string `json:"raw"`
+	ProductCount int    `json:"productCount"`
+}
+
+type Brand struct {
+	ID           int      `json:"id"`
+	Slug         string   `json:"slug"`
+	Name         string   `json:"name"`
+	Logo         string   `json:"logo,omitempty"`
+	Website      string   `json:"website,omitempty"`
+	Aliases      []string `json:"aliases,omitempty"`
+	ProductCount int      `json:"productCount"`
+}
+
+type Store struct {
+	ID           int        `json:"id"`
+	Slug         string     `json:"slug"`
+	Name         string     `json:"name"`
+	Website      string     `json:"website,omitempty"`
+	Logo         string     `json:"logo,omitempty"`
+	City         string     `json:"city,omitempty"`
+	DeliveryInfo string     `json:"deliveryInfo,omitempty"`
+	LastImportAt *time.Time `json:"lastImportAt,omitempty"`
+	ProductCount int        `json:"productCount"`
+}
+
+// AdminStore is a store together with the configuration of its product
+// feed, which only admins see.
+type AdminStore struct {
+	*Store
+	FeedURL    string `json:"feedUrl"`
+	FeedFormat string `json:"feedFormat"`
+}
+
+type StoreCategoryCount struct {
+	Slug         string `json:"slug"`
+	NameMK       string `json:"nameMk"`
+	NameEN       string `json:"nameEn"`
+	ProductCount int    `json:"productCount"`
+}
+
+type StoreDetails struct {
+	*Store
+	Categories []*StoreCategoryCount `json:"categories"`
+	// PriceIndex is the store's average price for parts other stores
+	// also list, as a percentage of their average; below 100 is cheaper.
+	PriceIndex            *float64   `json:"priceIndex"`
+	ComparedProducts      int        `json:"comparedProducts"`
+	LastSeenAt            *time.Time `json:"lastSeenAt"`
+	MissingFromLastImport int        `json:"missingFromLastImport"`
+	Freshness             string     `json:"freshness"`
 }
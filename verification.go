@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+const (
+	defaultAppBaseURL          = "http://pcpartsmk.store"
+	verificationResendInterval = 2 * time.Minute
+)
+
+func (s *PostgressStore) MarkUserVerified(id int) error {
+	_, err := s.db.Exec(`
+		UPDATE users SET verified_at = NOW() WHERE id = $1 AND verified_at IS NULL
+	`, id)
+	return err
+}
+
+// ClaimVerificationEmail records that a verification email is about to be
+// sent to the user. It returns false when one was already sent within the
+// interval, so concurrent requests cannot bypass the throttle.
+func (s *PostgressStore) ClaimVerificationEmail(userID int, interval time.Duration) (bool, error) {
+	res, err := s.db.Exec(`
+		UPDATE users SET verification_sent_at = NOW()
+		WHERE id = $1 AND (verification_sent_at IS NULL OR verification_sent_at < NOW() - make_interval(secs => $2))
+	`, userID, interval.Seconds())
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func appBaseURL() string {
+	if v := os.Getenv("APP_BASE_URL"); v != "" {
+		return v
+	}
+	return defaultAppBaseURL
+}
+
+// sendVerificationEmail mails the user a link to confirm their address.
+// Failures are logged; the user can ask for the email again.
+func (s *APIServer) sendVerificationEmail(user *User) {
+	if err := s.trySendVerificationEmail(user); err != nil {
+		log.Printf("could not send verification email to user %d: %v", user.ID, err)
+	}
+}
+
+func (s *APIServer) trySendVerificationEmail(user *User) error {
+	claimed, err := s.store.ClaimVerificationEmail(user.ID, verificationResendInterval)
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		return statusErrorf(http.StatusTooManyRequests, "a verification email was sent recently, please try again in a few minutes")
+	}
+
+	token, err := GenerateEmailVerificationToken(user.ID, user.Email)
+	if err != nil {
+		return err
+	}
+	link := appBaseURL() + "/verify-email?token=" + url.QueryEscape(token)
+
+	return s.notifier.Notify(&Message{
+		To:      user.Email,
+		Subject: "Confirm your email address",
+		Body: fmt.Sprintf("Welcome to pcpartsmk!\n\nPlease confirm your email address by opening this link within 24 hours:\n\n%s\n\n"+
+			"If you did not create an account, you can ignore this email.\n", link),
+	})
+}
+
+// requireVerifiedUser returns the authenticated user's ID, refusing users
+// who have not confirmed their email address yet.
+func (s *APIServer) requireVerifiedUser(r *http.Request) (int, error) {
+	user, err := s.authenticate(r)
+	if err != nil {
+		return 0, err
+	}
+	if user.VerifiedAt == nil {
+		return 0, statusErrorf(http.StatusForbidden, "please verify your email address first")
+	}
+	return user.ID, nil
+}
+
+func (s *APIServer) handleVerifyEmail(w http.ResponseWriter, r *http.Request) error {
+	userID, email, err := ParseEmailVerificationToken(r.URL.Query().Get("token"))
+	if err != nil {
+		return err
+	}
+
+	user, err := s.store.GetUserByID(userID)
+	if err != nil {
+		return fmt.Errorf("could not get user: %w", err)
+	}
+	if user == nil || user.Email != email {
+		return fmt.Errorf("invalid or expired verification token")
+	}
+
+	if err := s.store.MarkUserVerified(user.ID); err != nil {
+		return fmt.Errorf("failed to verify email: %w", err)
+	}
+	return WriteJSON(w, http.StatusOK, map[string]string{"message": "email verified"})
+}
+
+func (s *APIServer) handleResendVerificationEmail(w http.ResponseWriter, r *http.Request) error {
+	user, err := s.authenticate(r)
+	if err != nil {
+		return err
+	}
+	if user.VerifiedAt != nil {
+		return statusErrorf(http.StatusConflict, "email address is already verified")
+	}
+
+	if err := s.trySendVerificationEmail(user); err != nil {
+		return err
+	}
+	return WriteJSON(w, http.StatusOK, map[string]string{"message": "verification email sent"})
+}
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+)
+
+const (
+	defaultWishlistName   = "Saved"
+	maxWishlistNameLength = 100
+)
+
+func (s *PostgressStore) CreateWishlistTables() error {
+	_, err := s.db.Exec(`
+    CREATE TABLE IF NOT EXISTS wishlists (
+        id SERIAL PRIMARY KEY,
+        user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+        name TEXT NOT NULL,
+        created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+        UNIQUE (user_id, name)
+    );
+    CREATE TABLE IF NOT EXISTS wishlist_items (
+        id SERIAL PRIMARY KEY,
+        wishlist_id INTEGER NOT NULL REFERENCES wishlists(id) ON DELETE CASCADE,
+        product_id INTEGER NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+        saved_price BIGINT NOT NULL,
+        added_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+        UNIQUE (wishlist_id, product_id)
+    );
+    `)
+	return err
+}
+
+func (s *PostgressStore) CreateWishlist(userID int, name string) (*Wishlist, error) {
+	w := &Wishlist{UserID: userID, Name: name, Items: []*WishlistItem{}}
+	err := s.db.QueryRow(`
+        INSERT INTO wishlists (user_id, name) VALUES ($1, $2)
+        RETURNING id, created_at
+    `, userID, name).Scan(&w.ID, &w.CreatedAt)
+	return w, err
+}
+
+// GetDefaultWishlist returns the user's first list, creating it on demand.
+func (s *PostgressStore) GetDefaultWishlist(userID int) (*Wishlist, error) {
+	w := &Wishlist{UserID: userID}
+	err := s.db.QueryRow(`
+        SELECT id, name, created_at FROM wishlists
+        WHERE user_id = $1
+        ORDER BY id
+        LIMIT 1
+    `, userID).Scan(&w.ID, &w.Name, &w.CreatedAt)
+	if err == sql.ErrNoRows {
+		return s.CreateWishlist(userID, defaultWishlistName)
+	}
+	return w, err
+}
+
+func (s *PostgressStore) GetWishlistByID(id int) (*Wishlist, error) {
+	w := new(Wishlist)
+	err := s.db.QueryRow(`
+        SELECT id, user_id, name, created_at FROM wishlists WHERE id = $1
+    `, id).Scan(&w.ID, &w.UserID, &w.Name, &w.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return w, err
+}
+
+// GetWishlistsByUserID returns the user's lists with their items.
+func (s *PostgressStore) GetWishlistsByUserID(userID int) ([]*Wishlist, error) {
+	rows, err := s.db.Query(`
+        SELECT id, user_id, name, created_at FROM wishlists
+        WHERE user_id = $1
+        ORDER BY id
+    `, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lists := []*Wishlist{}
+	byID := map[int]*Wishlist{}
+	var ids []int
+	for rows.Next() {
+		w := &Wishlist{Items: []*WishlistItem{}}
+		if err := rows.Scan(&w.ID, &w.UserID, &w.Name, &w.CreatedAt); err != nil {
+			return nil, err
+		}
+		lists = append(lists, w)
+		byID[w.ID] = w
+		ids = append(ids, w.ID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return lists, nil
+	}
+
+	itemRows, err := s.db.Query(`
+        SELECT wi.wishlist_id, wi.saved_price, wi.added_at,
+               p.last_seen_at >= (SELECT MAX(last_seen_at) FROM products WHERE store = p.store) - INTERVAL '1 hour',
+               p.id, p.title, p.manufacturer, p.price, p.code, p.warranty,
+               p.link, p.category, p.description, p.image, p.store, p.availability, p.delivery_estimate
+        FROM wishlist_items wi
+        JOIN products p ON p.id = wi.product_id
+        WHERE wi.wishlist_id = ANY($1)
+        ORDER BY wi.added_at DESC
+    `, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer itemRows.Close()
+
+	for itemRows.Next() {
+		var wishlistID int
+		var seen bool
+		item := &WishlistItem{Product: new(Product)}
+		p := item.Product
+		if err := itemRows.Scan(
+			&wishlistID, &item.SavedPrice, &item.AddedAt, &seen,
+			&p.ID, &p.Title, &p.Manufacturer, &p.Price, &p.Code, &p.Warranty,
+			&p.Link, &p.Category, &p.Description, &p.Image, &p.Store, &p.Availability, &p.DeliveryEstimate,
+		); err != nil {
+			return nil, err
+		}
+		item.ProductID = p.ID
+		item.CurrentPrice = p.Price
+		item.PriceChange = p.Price - item.SavedPrice
+		// A listing missing from the store's latest import is gone.
+		item.Availability = p.Availability
+		if !seen {
+			item.Availability = AvailabilityOutOfStock
+		}
+		byID[wishlistID].Items = append(byID[wishlistID].Items, item)
+	}
+	return lists, itemRows.Err()
+}
+
+func (s *PostgressStore) RenameWishlist(id int, name string) error {
+	_, err := s.db.Exec(`UPDATE wishlists SET name = $2 WHERE id = $1`, id, name)
+	return err
+}
+
+func (s *PostgressStore) DeleteWishlist(id int) error {
+	_, err := s.db.Exec(`DELETE FROM wishlists WHERE id = $1`, id)
+	return err
+}
+
+// AddProductToWishlist saves a product at its current price. Saving it again
+// keeps the original price so that the change since then stays visible.
+func (s *PostgressStore) AddProductToWishlist(wishlistID, productID int) error {
+	_, err := s.db.Exec(`
+        INSERT INTO wishlist_items (wishlist_id, product_id, saved_price)
+        SELECT $1, id, price FROM products WHERE id = $2
+        ON CONFLICT (wishlist_id, product_id) DO NOTHING
+    `, wishlistID, productID)
+	return err
+}
+
+func (s *PostgressStore) RemoveProductFromWishlist(wishlistID, productID int) error {
+	res, err := s.db.Exec(`
+        DELETE FROM wishlist_items WHERE wishlist_id = $1 AND product_id = $2
+    `, wishlistID, productID)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func validateWishlistName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", statusErrorf(http.StatusUnprocessableEntity, "wishlist name is required")
+	}
+	if len([]rune(name)) > maxWishlistNameLength {
+		return "", statusErrorf(http.StatusUnprocessableEntity, "wishlist name must be at most %d characters", maxWishlistNameLength)
+	}
+	return name, nil
+}
+
+// getOwnedWishlist loads the wishlist named by the {id} route variable, where
+// "default" means the user's first list, and checks its owner.
+func (s *APIServer) getOwnedWishlist(r *http.Request) (*Wishlist, error) {
+	userID, err := s.userIDFromRequest(r)
+	if err != nil {
+		return nil, err
+	}
+
+	idStr := mux.Vars(r)["id"]
+	if idStr == "default" {
+		return s.store.GetDefaultWishlist(userID)
+	}
+
+	var id int
+	if _, err := fmt.Sscanf(idStr, "%d", &id); err != nil {
+		return nil, fmt.Errorf("invalid wishlist ID")
+	}
+	w, err := s.store.GetWishlistByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("could not get wishlist: %w", err)
+	}
+	if w == nil || w.UserID != userID {
+		return nil, statusErrorf(http.StatusNotFound, "wishlist not found")
+	}
+	return w, nil
+}
+
+func (s *APIServer) handleGetWishlists(w http.ResponseWriter, r *http.Request) error {
+	userID, err := s.userIDFromRequest(r)
+	if err != nil {
+		return err
+	}
+
+	lists, err := s.store.GetWishlistsByUserID(userID)
+	if err != nil {
+		return fmt.Errorf("could not get wishlists: %w", err)
+	}
+	return WriteJSON(w, http.StatusOK, lists)
+}
+
+func (s *APIServer) handleCreateWishlist(w http.ResponseWriter, r *http.Request) error {
+	userID, err := s.userIDFromRequest(r)
+	if err != nil {
+		return err
+	}
+
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return err
+	}
+	name, err := validateWishlistName(req.Name)
+	if err != nil {
+		return err
+	}
+
+	list, err := s.store.CreateWishlist(userID, name)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return statusErrorf(http.StatusConflict, "a wishlist named %q already exists", name)
+		}
+		return fmt.Errorf("failed to create wishlist: %w", err)
+	}
+	return WriteJSON(w, http.StatusCreated, list)
+}
+
+func (s *APIServer) handleRenameWishlist(w http.ResponseWriter, r *http.Request) error {
+	list, err := s.getOwnedWishlist(r)
+	if err != nil {
+		return err
+	}
+
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return err
+	}
+	name, err := validateWishlistName(req.Name)
+	if err != nil {
+		return err
+	}
+
+	if err := s.store.RenameWishlist(list.ID, name); err != nil {
+		if isUniqueViolation(err) {
+			return statusErrorf(http.StatusConflict, "a wishlist named %q already exists", name)
+		}
+		return fmt.Errorf("failed to rename wishlist: %w", err)
+	}
+	return WriteJSON(w, http.StatusOK, map[string]string{"message": "wishlist renamed"})
+}
+
+func (s *APIServer) handleDeleteWishlist(w http.ResponseWriter, r *http.Request) error {
+	list, err := s.getOwnedWishlist(r)
+	if err != nil {
+		return err
+	}
+
+	if err := s.store.DeleteWishlist(list.ID); err != nil {
+		return fmt.Errorf("failed to delete wishlist: %w", err)
+	}
+	return WriteJSON(w, http.StatusOK, map[string]string{"message": "wishlist deleted"})
+}
+
+func (s *APIServer) handleAddProductToWishlist(w http.ResponseWriter, r *http.Request) error {
+	list, err := s.getOwnedWishlist(r)
+	if err != nil {
+		return err
+	}
+
+	var req struct {
+		ProductID int `json:"productID"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return err
+	}
+	product, err := s.store.GetProductByID(req.ProductID)
+	if err != nil {
+		return fmt.Errorf("could not fetch product: %w", err)
+	}
+	if product == nil {
+		return statusErrorf(http.StatusNotFound, "product not found")
+	}
+
+	if err := s.store.AddProductToWishlist(list.ID, product.ID); err != nil {
+		return fmt.Errorf("failed to save product: %w", err)
+	}
+	return WriteJSON(w, http.StatusOK, map[string]any{"message": "product saved", "wishlistID": list.ID})
+}
+
+func (s *APIServer) handleRemoveProductFromWishlist(w http.ResponseWriter, r *http.Request) error {
+	list, err := s.getOwnedWishlist(r)
+	if err != nil {
+		return err
+	}
+
+	var productID int
+	if _, err := fmt.Sscanf(mux.Vars(r)["productID"], "%d", &productID); err != nil {
+		return fmt.Errorf("invalid product ID")
+	}
+
+	if err := s.store.RemoveProductFromWishlist(list.ID, productID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return statusErrorf(http.StatusNotFound, "product is not in this wishlist")
+		}
+		return fmt.Errorf("failed to remove product: %w", err)
+	}
+	return WriteJSON(w, http.StatusOK, map[string]string{"message": "product removed"})
+}